@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/handler"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+const (
+	exitCodeOK    = 0
+	exitCodeError = 1
+	// 2 is used for invalid arguments (by the flag package)
+	exitCodeNoAddr         = 3
+	exitCodeUnauthorized   = 4
+	exitCodeNotFound       = 5
+	exitCodeSettingMissing = 6
+	exitCodeDeviceNotFound = 7
+	exitCodeTransport      = 8
+	exitCodeParse          = 9
+	// Only used with detailed exit codes, otherwise exitCodeOK is used
+	exitCodeUpdated = 10
+)
+
+var errNoAddrs = errors.New("no global unicast IPv6 address found on interface")
+
+type errorKind struct {
+	err      error
+	name     string
+	exitCode int
+}
+
+// Known kinds of errors, with more specific kinds listed first
+var errorKinds = []errorKind{
+	{err: errNoAddrs, name: "no-address", exitCode: exitCodeNoAddr},
+	{err: handler.ErrNoMatchingAddrs, name: "no-address", exitCode: exitCodeNoAddr},
+	{err: plex.ErrUnauthorized, name: "unauthorized", exitCode: exitCodeUnauthorized},
+	{err: plex.ErrDeviceNotFound, name: "device-not-found", exitCode: exitCodeDeviceNotFound},
+	{err: plex.ErrSettingMissing, name: "setting-missing", exitCode: exitCodeSettingMissing},
+	{err: plex.ErrNotFound, name: "not-found", exitCode: exitCodeNotFound},
+	{err: plex.ErrParse, name: "parse", exitCode: exitCodeParse},
+	{err: plex.ErrTransport, name: "transport", exitCode: exitCodeTransport},
+}
+
+// classifyError returns the (most specific) kind of error
+func classifyError(err error) errorKind {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k
+		}
+	}
+
+	return errorKind{err: err, name: "other", exitCode: exitCodeError}
+}
+
+// exitCodeForError returns the exit code matching the (most specific) kind of error
+func exitCodeForError(err error) int {
+	return classifyError(err).exitCode
+}
+
+// exitCodeForResult returns the exit code for a successful run, distinguishing between updated and unchanged
+// custom access URLs only if detailed exit codes are enabled
+func exitCodeForResult(cfg *config.Config, result handler.UpdateResult) int {
+	if cfg.DetailedExitCodes && result == handler.UpdateResultUpdated {
+		return exitCodeUpdated
+	}
+	return exitCodeOK
+}
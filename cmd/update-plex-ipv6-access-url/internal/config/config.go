@@ -4,48 +4,228 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/handler"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
 	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+const (
+	// CommandLogin signs in to plex.tv in order to obtain a token
+	CommandLogin = "login"
 )
 
 type Config struct {
 	Version bool
+	Command string
+
+	ToolConfigPath string
+
+	Debug             bool
+	ColorizeLogs      bool
+	LogFormat         LogFormat
+	LogFile           string
+	LogFileMaxSize    int
+	LogFileMaxBackups int
+	LogTimestamps     bool
+	LogLevels         bool
+
+	ServerAddr    string
+	InterfaceName string
 
-	Debug        bool
-	ColorizeLogs bool
+	CAFile             string
+	CertSHA256         string
+	TLSServerName      string
+	InsecureSkipVerify bool
+
+	AddrPreference  handler.AddrPreference
+	IncludePrefixes handler.PrefixList
+	ExcludePrefixes handler.PrefixList
+	InterfaceID     netip.Addr
+	PreferStatic    bool
+	Sticky          bool
+	GracePeriod     time.Duration
+
+	IncludeTemporary  bool
+	IncludeDeprecated bool
+	IncludeTentative  bool
+	IncludeDADFailed  bool
 
-	ServerAddr     string
-	InterfaceName  string
-	AddrPreference handler.AddrPreference
 	ConfigPath     string
 	Token          string
+	TokenFile      string
+	TokenStdin     bool
+	ValidateToken  bool
 	Capitalization handler.IPv6URLCapitalization
+	Position       handler.URLPosition
+	HostnameSource handler.HostnameSource
+	ResourcesAPI   handler.ResourcesAPI
+	CacheTTL       time.Duration
 	Timeout        int
+	Deadline       time.Duration
+
+	Attempts       int
+	PlexTVAttempts int
+	RetryDelay     time.Duration
+	RetryJitter    float64
+
+	Watch         bool
+	WatchDebounce time.Duration
+
+	StateFilePath  string
+	TokenStorePath string
+
+	Offline        bool
+	RestartCommand string
+
+	DryRun            bool
+	DetailedExitCodes bool
+	OutputFormat      OutputFormat
 }
 
 func Init() *Config {
 	cfg := new(Config)
 	flag.BoolVar(&cfg.Version, "v", false, "prints the version")
 	flag.BoolVar(&cfg.Version, "version", false, "prints the version")
+	flag.StringVar(&cfg.ToolConfigPath, flagNameToolConfig, "", "Path to config file (YAML) for this tool, using flag names as keys")
 	flag.BoolVar(&cfg.Debug, "debug", false, "enable debug logging")
 	flag.BoolVar(&cfg.ColorizeLogs, "colorize-logs", false, "colorize log messages")
+	flag.TextVar(&cfg.LogFormat, "log-format", LogFormatConsole, "Log message format (console|json)")
+	flag.StringVar(&cfg.LogFile, "log-file", "", "Path to file to write log messages to instead of stdout/stderr")
+	flag.IntVar(&cfg.LogFileMaxSize, "log-file-max-size", 10, "Size (in MB) at which the log file is rotated")
+	flag.IntVar(&cfg.LogFileMaxBackups, "log-file-max-backups", 3, "Number of rotated log files to keep (0 to discard log messages on rotation)")
+	flag.BoolVar(&cfg.LogTimestamps, "log-timestamps", false, "include timestamps in console log messages (always included in json log messages)")
+	flag.BoolVar(&cfg.LogLevels, "log-levels", false, "include levels in console log messages (always included in json log messages)")
 	flag.StringVar(&cfg.ServerAddr, "address", "", "Plex server's address in format http[s]://host:port")
 	flag.StringVar(&cfg.InterfaceName, "interface", "", "Name of network interface to use for IPv6 access")
-	flag.TextVar(&cfg.AddrPreference, "use", handler.AddrPreferenceFirst, "Which IPv6 address(es) to use if multiple are found on the interface (first|last|all)")
+	flag.StringVar(&cfg.CAFile, "ca-file", "", "Path to PEM file containing CA certificates to trust when connecting to the Plex server via HTTPS (instead of the system's)")
+	flag.StringVar(&cfg.CertSHA256, "cert-sha256", "", "SHA-256 fingerprint of the certificate the Plex server needs to present, trusting it regardless of issuer and names")
+	flag.StringVar(&cfg.TLSServerName, "tls-server-name", "", "Name to verify the Plex server's certificate against instead of the host in address, e.g. [dashed-ip].[server-hash].plex.direct")
+	flag.BoolVar(&cfg.InsecureSkipVerify, "insecure-skip-verify", false, "Do not verify the Plex server's certificate at all (insecure)")
+	flag.TextVar(&cfg.AddrPreference, "use", handler.AddrPreferenceFirst, "Which IPv6 address(es) to use if multiple are found on the interface (first|last|all|longest-lifetime)")
+	flag.TextVar(&cfg.IncludePrefixes, "include-prefixes", handler.PrefixList{}, "Comma-separated list of prefixes (CIDRs), only IPv6 addresses contained in any of them will be considered")
+	flag.TextVar(&cfg.ExcludePrefixes, "exclude-prefixes", handler.PrefixList{}, "Comma-separated list of prefixes (CIDRs), IPv6 addresses contained in any of them will not be considered")
+	flag.TextVar(&cfg.InterfaceID, "interface-id", netip.Addr{}, "Interface identifier (lower 64 bits) IPv6 addresses need to have in order to be considered, e.g. ::1234")
+	flag.BoolVar(&cfg.PreferStatic, "prefer-static", false, "only consider statically configured IPv6 addresses if there are any (Linux only)")
+	flag.BoolVar(&cfg.Sticky, "sticky", false, "keep using the previously published IPv6 address for as long as it is assigned to the interface and not deprecated (does not apply to 'use all')")
+	flag.DurationVar(&cfg.GracePeriod, "grace-period", 0, "How long to keep publishing previous IPv6 addresses after a change, for as long as they are assigned to the interface and valid (0 to remove them right away)")
+	flag.BoolVar(&cfg.IncludeTemporary, "include-temporary", false, "consider temporary (privacy extension) IPv6 addresses (Linux only, always considered on other platforms)")
+	flag.BoolVar(&cfg.IncludeDeprecated, "include-deprecated", false, "consider deprecated IPv6 addresses (Linux only, always considered on other platforms)")
+	flag.BoolVar(&cfg.IncludeTentative, "include-tentative", false, "consider tentative IPv6 addresses, for which duplicate address detection did not complete yet (Linux only, always considered on other platforms)")
+	flag.BoolVar(&cfg.IncludeDADFailed, "include-dadfailed", false, "consider IPv6 addresses for which duplicate address detection failed (Linux only, always considered on other platforms)")
 	flag.StringVar(&cfg.ConfigPath, "config", "", "Path to Plex config (Preferences.xml)")
 	flag.StringVar(&cfg.Token, "token", "", "Plex access token (X-Plex-Token) [required if 'config' flag is/cannot be provided]")
+	flag.StringVar(&cfg.TokenFile, "token-file", "", "Path to file containing the Plex access token (X-Plex-Token)")
+	flag.BoolVar(&cfg.TokenStdin, "token-stdin", false, "read the Plex access token (X-Plex-Token) from stdin")
+	flag.BoolVar(&cfg.ValidateToken, "validate-token", false, "check that the Plex access token is valid and belongs to the server owner before updating")
 	flag.TextVar(&cfg.Capitalization, "capitalization", handler.IPv6URLCapitalizationLower, "Capitalization to use for dashed IPv6 address in Plex custom access URL (upper|lower)")
+	flag.TextVar(&cfg.Position, "position", handler.URLPositionKeep, "Where to put the IPv6 custom access URLs in the list of custom access URLs (keep|front|back), keep replaces the previous ones in place")
+	flag.TextVar(&cfg.HostnameSource, "hostname-source", handler.HostnameSourcePlexTV, "Where to get the Plex server's plex.direct hostname from (plextv|certificate|auto), auto tries the certificate first and falls back to plex.tv")
+	flag.TextVar(&cfg.ResourcesAPI, "resources-api", handler.ResourcesAPIAuto, "Which plex.tv resources API to use for determining the plex.direct hostname (auto|v2|legacy), auto tries v2 first and falls back to legacy")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", 24*time.Hour, "How long to cache the Plex server's machine identifier and plex.direct hostname in the state file (0 to disable caching)")
 	flag.IntVar(&cfg.Timeout, "timeout", 5, "Plex API request timeout (in seconds)")
+	flag.DurationVar(&cfg.Deadline, "deadline", 0, "Maximum time an update may take overall, including retries (0 for no deadline)")
+	flag.IntVar(&cfg.Attempts, "attempts", 3, "Maximum number of attempts for Plex server API requests (1 to disable retries)")
+	flag.IntVar(&cfg.PlexTVAttempts, "plextv-attempts", 3, "Maximum number of attempts for plex.tv API requests (1 to disable retries)")
+	flag.DurationVar(&cfg.RetryDelay, "retry-delay", time.Second, "Delay before retrying a failed Plex API request, doubled for each further retry (up to 1m)")
+	flag.Float64Var(&cfg.RetryJitter, "retry-jitter", 0.2, "Fraction by which retry delays are randomly increased/decreased (0 to 1)")
+	flag.BoolVar(&cfg.Watch, "watch", false, "keep running and update Plex whenever the IPv6 addresses on the interface change (Linux only)")
+	flag.DurationVar(&cfg.WatchDebounce, "watch-debounce", 5*time.Second, "Time to wait for further address changes before updating Plex in watch mode")
+	flag.StringVar(&cfg.StateFilePath, "state-file", state.DefaultPath(), "Path to file used to persist state between runs")
+	flag.StringVar(&cfg.TokenStorePath, "token-store", state.DefaultTokenStorePath(), "Path to file used to store the Plex access token obtained via the 'login' command")
+	flag.BoolVar(&cfg.Offline, "offline", false, "update the Plex config (Preferences.xml) directly instead of using the Plex server's API [requires 'config' flag]")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "show how the custom access URLs would be changed without actually changing them")
+	flag.TextVar(&cfg.OutputFormat, "output", OutputFormatText, "Output format (text|json), json prints a single run summary to stdout and logs to stderr")
+	flag.BoolVar(&cfg.DetailedExitCodes, "detailed-exit-codes", false, "exit with code 10 if the custom access URLs were (or, in dry run mode, would be) updated")
+	flag.StringVar(&cfg.RestartCommand, "restart-command", "", "Command to run in order to restart the Plex server after updating the Plex config in offline mode")
 	flag.Parse()
+
+	// Subcommand, optionally followed by further flags
+	if flag.NArg() > 0 {
+		cfg.Command = flag.Arg(0)
+		if cfg.Command != CommandLogin {
+			_, _ = fmt.Fprintf(flag.CommandLine.Output(), "unknown command: %s\n", cfg.Command)
+			os.Exit(2)
+		}
+
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+		if flag.NArg() > 0 {
+			_, _ = fmt.Fprintf(flag.CommandLine.Output(), "unexpected arguments: %s\n", strings.Join(flag.Args(), " "))
+			os.Exit(2)
+		}
+	}
+
+	// Mirror flag.ExitOnError behaviour for invalid values from environment/tool config file
+	if err := applyEnvAndFile(flag.CommandLine, cfg.ToolConfigPath, os.LookupEnv); err != nil {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(2)
+	}
+
 	return cfg
 }
 
-func (c *Config) ReadValuesIfMissing() error {
-	if c.ServerAddr == "" {
+func (c *Config) AddrSelectionRules(s *state.State) handler.AddrSelectionRules {
+	rules := handler.AddrSelectionRules{
+		Preference:      c.AddrPreference,
+		IncludePrefixes: c.IncludePrefixes,
+		ExcludePrefixes: c.ExcludePrefixes,
+		InterfaceID:     c.InterfaceID,
+		PreferStatic:    c.PreferStatic,
+	}
+
+	if c.Sticky {
+		rules.StickyAddrs = s.PublishedAddrs
+	}
+
+	return rules
+}
+
+func (c *Config) PlanOptions(s *state.State) handler.PlanOptions {
+	return handler.PlanOptions{
+		Capitalization: c.Capitalization,
+		Position:       c.Position,
+		ManagedURLs:    s.ManagedURLs,
+	}
+}
+
+// ExcludedAddrFlags returns the flags of IPv6 addresses which should not be considered
+func (c *Config) ExcludedAddrFlags() internal.AddrFlags {
+	var flags internal.AddrFlags
+	if !c.IncludeTemporary {
+		flags |= internal.AddrFlagTemporary
+	}
+	if !c.IncludeDeprecated {
+		flags |= internal.AddrFlagDeprecated
+	}
+	if !c.IncludeTentative {
+		flags |= internal.AddrFlagTentative
+	}
+	if !c.IncludeDADFailed {
+		flags |= internal.AddrFlagDADFailed
+	}
+	return flags
+}
+
+func (c *Config) ReadValuesIfMissing(s *state.State) error {
+	// Read explicitly given token before prompting for anything, since the token may be read from stdin
+	if err := c.readTokenFromSources(os.LookupEnv, os.Stdin); err != nil {
+		return err
+	}
+
+	// Stdin was consumed in order to read the token, so we cannot prompt for anything
+	if c.TokenStdin {
+		if err := c.checkRequiredWithTokenStdin(); err != nil {
+			return err
+		}
+	}
+
+	if c.ServerAddr == "" && !c.Offline {
 		serverAddr, err := getInput("Enter the Plex server's address in format 'http[s]://host:port'")
 		if err != nil {
 			return fmt.Errorf("failed to read server address from console: %w", err)
@@ -61,15 +241,15 @@ func (c *Config) ReadValuesIfMissing() error {
 		c.InterfaceName = interfaceName
 	}
 
-	if c.ConfigPath == "" && c.Token == "" {
-		token, err := getInput("Enter a Plex access token (X-Plex-Token)")
+	if c.ConfigPath == "" && c.Offline {
+		configPath, err := getInput("Enter the path to the Plex config (Preferences.xml)")
 		if err != nil {
-			return fmt.Errorf("failed to read Plex token from console: %w", err)
+			return fmt.Errorf("failed to read Plex config path from console: %w", err)
 		}
-		c.Token = token
+		c.ConfigPath = configPath
 	}
 
-	if c.ConfigPath != "" {
+	if c.ConfigPath != "" && c.Token == "" {
 		config, err := plex.ReadConfigFile(c.ConfigPath)
 		if err != nil {
 			return fmt.Errorf("failed to read Plex config file from %s: %w", c.ConfigPath, err)
@@ -78,12 +258,30 @@ func (c *Config) ReadValuesIfMissing() error {
 		c.Token = config.Preferences.GetToken()
 	}
 
+	// Use token obtained via login, if any
+	if c.Token == "" && c.TokenStorePath != "" {
+		token, err := state.ReadTokenStore(c.TokenStorePath)
+		if err != nil {
+			return fmt.Errorf("failed to read stored Plex token: %w", err)
+		}
+		c.Token = token
+	}
+
+	if c.ConfigPath == "" && c.Token == "" {
+		token, err := getInput("Enter a Plex access token (X-Plex-Token)")
+		if err != nil {
+			return fmt.Errorf("failed to read Plex token from console: %w", err)
+		}
+		c.Token = token
+	}
+
 	return nil
 }
 
 func getInput(prompt string) (string, error) {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s: ", prompt)
+	// Prompt on stderr, keeping stdout free for the run summary when using JSON output
+	_, _ = fmt.Fprintf(os.Stderr, "%s: ", prompt)
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		return "", err
@@ -92,3 +290,28 @@ func getInput(prompt string) (string, error) {
 		return unicode.IsControl(r)
 	}), nil
 }
+
+func (c *Config) RetryPolicy() plex.RetryPolicy {
+	return plex.RetryPolicy{
+		Attempts:  c.Attempts,
+		BaseDelay: c.RetryDelay,
+		Jitter:    c.RetryJitter,
+	}
+}
+
+func (c *Config) PlexTVRetryPolicy() plex.RetryPolicy {
+	return plex.RetryPolicy{
+		Attempts:  c.PlexTVAttempts,
+		BaseDelay: c.RetryDelay,
+		Jitter:    c.RetryJitter,
+	}
+}
+
+func (c *Config) TLSOptions() plex.TLSOptions {
+	return plex.TLSOptions{
+		CAFile:             c.CAFile,
+		CertSHA256:         c.CertSHA256,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
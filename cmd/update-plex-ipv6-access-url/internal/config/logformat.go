@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+)
+
+type LogFormat string
+
+const (
+	// LogFormatConsole writes human-readable log messages
+	LogFormatConsole LogFormat = "console"
+	// LogFormatJSON writes one JSON object per log message
+	LogFormatJSON LogFormat = "json"
+)
+
+//goland:noinspection GoMixedReceiverTypes
+func (f LogFormat) String() string {
+	return string(f)
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f *LogFormat) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*f = ""
+		return nil
+	}
+
+	v := string(text)
+	switch v {
+	case string(LogFormatConsole):
+		*f = LogFormatConsole
+	case string(LogFormatJSON):
+		*f = LogFormatJSON
+	default:
+		return fmt.Errorf("invalid log format: %s", v)
+	}
+
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f LogFormat) MarshalText() (text []byte, err error) {
+	return []byte(f), nil
+}
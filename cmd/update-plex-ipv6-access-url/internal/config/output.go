@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+)
+
+type OutputFormat string
+
+const (
+	// OutputFormatText only outputs (human-readable) log messages
+	OutputFormatText OutputFormat = "text"
+	// OutputFormatJSON outputs a single JSON run summary on stdout, moving log messages to stderr
+	OutputFormatJSON OutputFormat = "json"
+)
+
+//goland:noinspection GoMixedReceiverTypes
+func (f OutputFormat) String() string {
+	return string(f)
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f *OutputFormat) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*f = ""
+		return nil
+	}
+
+	v := string(text)
+	switch v {
+	case string(OutputFormatText):
+		*f = OutputFormatText
+	case string(OutputFormatJSON):
+		*f = OutputFormatJSON
+	default:
+		return fmt.Errorf("invalid output format: %s", v)
+	}
+
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f OutputFormat) MarshalText() (text []byte, err error) {
+	return []byte(f), nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix = "PLEX_IPV6_"
+
+	flagNameToolConfig = "tool-config"
+)
+
+// Flags which cannot be set via environment variables or the tool config file
+var ignoredFlags = map[string]bool{
+	"v":                true,
+	"version":          true,
+	flagNameToolConfig: true,
+}
+
+// Flags which provide the same value in different ways. Within each group, only the flags given via the source with
+// the highest precedence are applied, so that e.g. a token file given on the command line is not overridden by a token
+// from an environment variable. Giving more than one of them via the same source is an error.
+var exclusiveFlags = [][]string{
+	{"token", "token-file"},
+}
+
+// Sources of flag values, in order of precedence
+const (
+	sourceFlag = iota
+	sourceEnv
+	sourceFile
+	sourceNone
+)
+
+// applyEnvAndFile sets any flag not given on the command line from the corresponding environment variable or,
+// if that is not set either, from the tool config file. Resulting precedence: flags > env > file > defaults
+func applyEnvAndFile(fs *flag.FlagSet, toolConfigPath string, lookupEnv func(key string) (string, bool)) error {
+	if toolConfigPath == "" {
+		toolConfigPath, _ = lookupEnv(envName(flagNameToolConfig))
+	}
+
+	var fileValues map[string]string
+	if toolConfigPath != "" {
+		var err error
+		fileValues, err = readToolConfigFile(toolConfigPath)
+		if err != nil {
+			return fmt.Errorf("failed to read tool config file from %s: %w", toolConfigPath, err)
+		}
+
+		for name := range fileValues {
+			if fs.Lookup(name) == nil || ignoredFlags[name] {
+				return fmt.Errorf("unknown key in tool config file %s: %s", toolConfigPath, name)
+			}
+		}
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	sourceOf := func(name string) int {
+		if given[name] {
+			return sourceFlag
+		}
+		if _, ok := lookupEnv(envName(name)); ok {
+			return sourceEnv
+		}
+		if _, ok := fileValues[name]; ok {
+			return sourceFile
+		}
+		return sourceNone
+	}
+
+	skipped := map[string]bool{}
+	for _, group := range exclusiveFlags {
+		best := sourceNone
+		for _, name := range group {
+			best = min(best, sourceOf(name))
+		}
+
+		var conflicting []string
+		for _, name := range group {
+			if sourceOf(name) == best {
+				conflicting = append(conflicting, name)
+			} else {
+				skipped[name] = true
+			}
+		}
+		if best != sourceNone && len(conflicting) > 1 {
+			return fmt.Errorf("only one of %s may be given via the same source", strings.Join(conflicting, ", "))
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] || ignoredFlags[f.Name] || skipped[f.Name] {
+			return
+		}
+
+		if value, ok := lookupEnv(envName(f.Name)); ok {
+			if err2 := fs.Set(f.Name, value); err2 != nil {
+				err = fmt.Errorf("invalid value %q for environment variable %s: %w", value, envName(f.Name), err2)
+			}
+			return
+		}
+
+		if value, ok := fileValues[f.Name]; ok {
+			if err2 := fs.Set(f.Name, value); err2 != nil {
+				err = fmt.Errorf("invalid value %q for key %s in tool config file %s: %w", value, f.Name, toolConfigPath, err2)
+			}
+		}
+	})
+
+	return err
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readToolConfigFile reads a YAML file mapping flag names to values. Lists are joined into a comma-separated value.
+func readToolConfigFile(path string) (map[string]string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err = yaml.Unmarshal(bytes, &data); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(data))
+	for k, v := range data {
+		value, err := toFlagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for key %s: %w", k, err)
+		}
+		values[k] = value
+	}
+
+	return values, nil
+}
+
+func toFlagValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(t), nil
+	case []interface{}:
+		elems := make([]string, 0, len(t))
+		for _, e := range t {
+			elem, err := toFlagValue(e)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, elem)
+		}
+		return strings.Join(elems, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+}
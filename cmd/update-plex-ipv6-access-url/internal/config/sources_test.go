@@ -0,0 +1,144 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Address   string
+	Token     string
+	TokenFile string
+	Debug     bool
+	Timeout   int
+	Debounce  time.Duration
+	Prefixes  string
+}
+
+func newTestFlagSet(cfg *testConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&cfg.Address, "address", "", "")
+	fs.StringVar(&cfg.Token, "token", "", "")
+	fs.StringVar(&cfg.TokenFile, "token-file", "", "")
+	fs.BoolVar(&cfg.Debug, "debug", false, "")
+	fs.IntVar(&cfg.Timeout, "timeout", 5, "")
+	fs.DurationVar(&cfg.Debounce, "watch-debounce", 5*time.Second, "")
+	fs.StringVar(&cfg.Prefixes, "include-prefixes", "", "")
+	return fs
+}
+
+func TestApplyEnvAndFile(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenArgs         []string
+		givenEnv          map[string]string
+		givenFileData     string
+		wantConfig        testConfig
+		wantErrorContains string
+	}{
+		{
+			name:       "uses defaults if neither env nor file are given",
+			wantConfig: testConfig{Timeout: 5, Debounce: 5 * time.Second},
+		},
+		{
+			name:          "uses values from file",
+			givenFileData: "address: http://localhost:32400\ndebug: true\ntimeout: 10\nwatch-debounce: 1m\ninclude-prefixes:\n  - 2001:db8::/32\n  - 2001:db9::/32\n",
+			wantConfig: testConfig{
+				Address:  "http://localhost:32400",
+				Debug:    true,
+				Timeout:  10,
+				Debounce: time.Minute,
+				Prefixes: "2001:db8::/32,2001:db9::/32",
+			},
+		},
+		{
+			name:          "prefers env over file",
+			givenEnv:      map[string]string{"PLEX_IPV6_TOKEN": "env-token", "PLEX_IPV6_WATCH_DEBOUNCE": "10s"},
+			givenFileData: "token: file-token\ntimeout: 10\n",
+			wantConfig:    testConfig{Token: "env-token", Timeout: 10, Debounce: 10 * time.Second},
+		},
+		{
+			name:          "prefers flags over env and file",
+			givenArgs:     []string{"-token", "flag-token"},
+			givenEnv:      map[string]string{"PLEX_IPV6_TOKEN": "env-token"},
+			givenFileData: "token: file-token\n",
+			wantConfig:    testConfig{Token: "flag-token", Timeout: 5, Debounce: 5 * time.Second},
+		},
+		{
+			name:          "prefers token file flag over token from env and file",
+			givenArgs:     []string{"-token-file", "/path/to/token"},
+			givenEnv:      map[string]string{"PLEX_IPV6_TOKEN": "env-token"},
+			givenFileData: "token: file-token\n",
+			wantConfig:    testConfig{TokenFile: "/path/to/token", Timeout: 5, Debounce: 5 * time.Second},
+		},
+		{
+			name:       "prefers token flag over token file from env",
+			givenArgs:  []string{"-token", "flag-token"},
+			givenEnv:   map[string]string{"PLEX_IPV6_TOKEN_FILE": "/path/to/token"},
+			wantConfig: testConfig{Token: "flag-token", Timeout: 5, Debounce: 5 * time.Second},
+		},
+		{
+			name:          "prefers token file from env over token from file",
+			givenEnv:      map[string]string{"PLEX_IPV6_TOKEN_FILE": "/path/to/token"},
+			givenFileData: "token: file-token\n",
+			wantConfig:    testConfig{TokenFile: "/path/to/token", Timeout: 5, Debounce: 5 * time.Second},
+		},
+		{
+			name:              "returns error if token and token file are given via the same source",
+			givenEnv:          map[string]string{"PLEX_IPV6_TOKEN": "env-token", "PLEX_IPV6_TOKEN_FILE": "/path/to/token"},
+			wantErrorContains: "only one of token, token-file may be given via the same source",
+		},
+		{
+			name:              "returns error for unknown key in file",
+			givenFileData:     "unknown: value\n",
+			wantErrorContains: "unknown key in tool config file",
+		},
+		{
+			name:              "returns error for invalid value in env",
+			givenEnv:          map[string]string{"PLEX_IPV6_TIMEOUT": "not-a-number"},
+			wantErrorContains: "invalid value \"not-a-number\" for environment variable PLEX_IPV6_TIMEOUT",
+		},
+		{
+			name:              "returns error for invalid value in file",
+			givenFileData:     "debug: not-a-bool\n",
+			wantErrorContains: "invalid value \"not-a-bool\" for key debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			var cfg testConfig
+			fs := newTestFlagSet(&cfg)
+			require.NoError(t, fs.Parse(tt.givenArgs))
+
+			var path string
+			if tt.givenFileData != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tt.givenFileData), 0600))
+			}
+
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := tt.givenEnv[key]
+				return v, ok
+			}
+
+			// WHEN
+			err := applyEnvAndFile(fs, path, lookupEnv)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantConfig, cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Environment variable set by systemd for services using LoadCredential=/SetCredential=
+	envCredentialsDirectory = "CREDENTIALS_DIRECTORY"
+	// Name of the systemd credential containing the token
+	credentialNameToken = "plex-token"
+)
+
+// readTokenFromSources determines the token from non-interactive sources given explicitly, in order of precedence:
+// token or token file flag (or environment variable/tool config), systemd credential, stdin. Only one of token and
+// token file is ever set, since applyEnvAndFile treats them as a single source.
+func (c *Config) readTokenFromSources(lookupEnv func(key string) (string, bool), stdin io.Reader) error {
+	if c.Token != "" {
+		return nil
+	}
+
+	if c.TokenFile != "" {
+		token, err := readTokenFile(c.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read Plex token from file: %w", err)
+		}
+		c.Token = token
+		return nil
+	}
+
+	if dir, ok := lookupEnv(envCredentialsDirectory); ok && dir != "" {
+		token, err := readTokenFile(filepath.Join(dir, credentialNameToken))
+		if err == nil {
+			c.Token = token
+			return nil
+		}
+		// Credentials may be used for other purposes than passing the token
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read Plex token from systemd credential: %w", err)
+		}
+	}
+
+	if c.TokenStdin {
+		token, err := readToken(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read Plex token from stdin: %w", err)
+		}
+		c.Token = token
+	}
+
+	return nil
+}
+
+// checkRequiredWithTokenStdin ensures that all arguments which would otherwise be prompted for are given
+func (c *Config) checkRequiredWithTokenStdin() error {
+	var missing string
+	switch {
+	case c.ServerAddr == "" && !c.Offline:
+		missing = "address"
+	case c.InterfaceName == "":
+		missing = "interface"
+	case c.ConfigPath == "" && c.Offline:
+		missing = "config"
+	default:
+		return nil
+	}
+
+	return fmt.Errorf("argument %s is required when using -token-stdin", missing)
+}
+
+func readTokenFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return readToken(f)
+}
+
+// readToken reads the token from the first line, ignoring surrounding whitespace
+func readToken(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no token found")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_readTokenFromSources(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenConfig       Config
+		givenTokenFile    string
+		givenCredential   string
+		givenStdin        string
+		wantToken         string
+		wantErrorContains string
+	}{
+		{
+			name:            "prefers token given via flag",
+			givenConfig:     Config{Token: "flag-token", TokenStdin: true},
+			givenTokenFile:  "file-token",
+			givenCredential: "credential-token",
+			givenStdin:      "stdin-token",
+			wantToken:       "flag-token",
+		},
+		{
+			name:            "prefers token file over systemd credential and stdin",
+			givenConfig:     Config{TokenStdin: true},
+			givenTokenFile:  "file-token\n",
+			givenCredential: "credential-token",
+			givenStdin:      "stdin-token",
+			wantToken:       "file-token",
+		},
+		{
+			name:            "prefers systemd credential over stdin",
+			givenConfig:     Config{TokenStdin: true},
+			givenCredential: "  credential-token\n",
+			givenStdin:      "stdin-token",
+			wantToken:       "credential-token",
+		},
+		{
+			name:        "reads first line from stdin",
+			givenConfig: Config{TokenStdin: true},
+			givenStdin:  "stdin-token\nsomething else\n",
+			wantToken:   "stdin-token",
+		},
+		{
+			name:        "does not read stdin unless requested",
+			givenConfig: Config{},
+			givenStdin:  "stdin-token",
+			wantToken:   "",
+		},
+		{
+			name:              "returns error for empty token file",
+			givenConfig:       Config{},
+			givenTokenFile:    "\n",
+			wantErrorContains: "failed to read Plex token from file: no token found",
+		},
+		{
+			name:              "returns error for missing token file",
+			givenConfig:       Config{TokenFile: "does-not-exist"},
+			wantErrorContains: "failed to read Plex token from file",
+		},
+		{
+			name:              "returns error for empty stdin",
+			givenConfig:       Config{TokenStdin: true},
+			wantErrorContains: "failed to read Plex token from stdin: no token found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			dir := t.TempDir()
+			if tt.givenTokenFile != "" {
+				tt.givenConfig.TokenFile = filepath.Join(dir, "token")
+				require.NoError(t, os.WriteFile(tt.givenConfig.TokenFile, []byte(tt.givenTokenFile), 0600))
+			}
+			credentialsDir := filepath.Join(dir, "credentials")
+			require.NoError(t, os.Mkdir(credentialsDir, 0700))
+			if tt.givenCredential != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(credentialsDir, credentialNameToken), []byte(tt.givenCredential), 0600))
+			}
+			lookupEnv := func(key string) (string, bool) {
+				if key == envCredentialsDirectory {
+					return credentialsDir, true
+				}
+				return "", false
+			}
+
+			// WHEN
+			err := tt.givenConfig.readTokenFromSources(lookupEnv, strings.NewReader(tt.givenStdin))
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantToken, tt.givenConfig.Token)
+			}
+		})
+	}
+}
+
+func TestConfig_checkRequiredWithTokenStdin(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenConfig       Config
+		wantErrorContains string
+	}{
+		{
+			name:        "accepts all required arguments",
+			givenConfig: Config{ServerAddr: "http://127.0.0.1:32400", InterfaceName: "eth0"},
+		},
+		{
+			name:        "accepts missing address in offline mode",
+			givenConfig: Config{InterfaceName: "eth0", ConfigPath: "Preferences.xml", Offline: true},
+		},
+		{
+			name:              "returns error for missing address",
+			givenConfig:       Config{InterfaceName: "eth0"},
+			wantErrorContains: "argument address is required when using -token-stdin",
+		},
+		{
+			name:              "returns error for missing interface",
+			givenConfig:       Config{ServerAddr: "http://127.0.0.1:32400"},
+			wantErrorContains: "argument interface is required when using -token-stdin",
+		},
+		{
+			name:              "returns error for missing config in offline mode",
+			givenConfig:       Config{InterfaceName: "eth0", Offline: true},
+			wantErrorContains: "argument config is required when using -token-stdin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			err := tt.givenConfig.checkRequiredWithTokenStdin()
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
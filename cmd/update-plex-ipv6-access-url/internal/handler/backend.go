@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+// Backend provides access to the server's identity and preferences
+type Backend interface {
+	GetMachineIdentifier(ctx context.Context) (string, error)
+	GetPreferences(ctx context.Context) (Preferences, error)
+	UpdateCustomConnections(ctx context.Context, customConnections string) error
+}
+
+type Preferences struct {
+	CustomConnections []string
+	MappedPort        string
+}
+
+// ApiBackend reads from/writes to a running Plex server via its API
+type ApiBackend struct {
+	client ApiClient
+}
+
+func NewApiBackend(client ApiClient) *ApiBackend {
+	return &ApiBackend{
+		client: client,
+	}
+}
+
+func (b *ApiBackend) GetMachineIdentifier(ctx context.Context) (string, error) {
+	identity, err := b.client.GetIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return identity.MachineIdentifier, nil
+}
+
+func (b *ApiBackend) GetPreferences(ctx context.Context) (Preferences, error) {
+	preferences, err := b.client.GetPreferences(ctx)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	customConnections, err := getCustomConnections(preferences)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	mappedPort, err := getMappedPort(preferences)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	return Preferences{
+		CustomConnections: customConnections,
+		MappedPort:        mappedPort,
+	}, nil
+}
+
+func (b *ApiBackend) UpdateCustomConnections(ctx context.Context, customConnections string) error {
+	return b.client.UpdateCustomConnections(ctx, customConnections)
+}
+
+// ConfigFileBackend reads from/writes to the Plex config file (Preferences.xml) directly. Plex reads the file
+// on startup and may overwrite it while running, so the server should be stopped or restarted after an update.
+type ConfigFileBackend struct {
+	path string
+}
+
+func NewConfigFileBackend(path string) *ConfigFileBackend {
+	return &ConfigFileBackend{
+		path: path,
+	}
+}
+
+func (b *ConfigFileBackend) GetMachineIdentifier(_ context.Context) (string, error) {
+	config, err := plex.ReadConfigFile(b.path)
+	if err != nil {
+		return "", err
+	}
+
+	machineIdentifier := config.Preferences.GetProcessedMachineIdentifier()
+	if machineIdentifier == "" {
+		return "", fmt.Errorf("%w: no machine identifier found in Plex config file: %s", plex.ErrSettingMissing, b.path)
+	}
+
+	return machineIdentifier, nil
+}
+
+func (b *ConfigFileBackend) GetPreferences(_ context.Context) (Preferences, error) {
+	config, err := plex.ReadConfigFile(b.path)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	mappedPort := config.Preferences.GetMappedPort()
+	if mappedPort == "" {
+		return Preferences{}, fmt.Errorf("%w: no mapped port found in Plex config file: %s", plex.ErrSettingMissing, b.path)
+	}
+
+	return Preferences{
+		CustomConnections: config.Preferences.GetCustomConnections(),
+		MappedPort:        mappedPort,
+	}, nil
+}
+
+func (b *ConfigFileBackend) UpdateCustomConnections(_ context.Context, customConnections string) error {
+	// Re-read config right before writing to keep the window for overwriting other changes as small as possible
+	config, err := plex.ReadConfigFile(b.path)
+	if err != nil {
+		return err
+	}
+
+	config.Preferences.SetCustomConnections(customConnections)
+
+	return plex.WriteConfigFile(config)
+}
+
+func getCustomConnections(preferences plex.PreferencesDTO) ([]string, error) {
+	setting, err := preferences.GetSettingByID(plex.SettingIDCustomConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(setting.Value, ","), nil
+}
+
+func getMappedPort(preferences plex.PreferencesDTO) (string, error) {
+	manualPortMappingMode, err := preferences.GetSettingByID(plex.SettingIDManualPortMappingMode)
+	if err != nil {
+		return "", err
+	}
+
+	var portSetting plex.SettingDTO
+	if manualPortMappingMode.IsEnabledBoolSetting() {
+		portSetting, err = preferences.GetSettingByID(plex.SettingIDManualPortMappingPort)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		portSetting, err = preferences.GetSettingByID(plex.SettingIDLastAutomaticMappedPort)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return portSetting.Value, nil
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+func TestConfigFileBackend_RoundTrip(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "Preferences.xml")
+	err := os.WriteFile(path, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<Preferences ProcessedMachineIdentifier="`+testMachineIdentifier+`" ManualPortMappingMode="1" ManualPortMappingPort="32400" FriendlyName="MyPlexServer" customConnections="https://plex.example.com:443,https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400"/>`), 0600)
+	require.NoError(t, err)
+	backend := NewConfigFileBackend(path)
+
+	// WHEN
+	machineIdentifier, err := backend.GetMachineIdentifier(context.Background())
+	require.NoError(t, err)
+	preferences, err := backend.GetPreferences(context.Background())
+	require.NoError(t, err)
+	err = backend.UpdateCustomConnections(context.Background(), "https://plex.example.com:443,https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400")
+	require.NoError(t, err)
+	updated, err := backend.GetPreferences(context.Background())
+	require.NoError(t, err)
+
+	// THEN
+	assert.Equal(t, testMachineIdentifier, machineIdentifier)
+	assert.Equal(t, Preferences{
+		CustomConnections: []string{
+			"https://plex.example.com:443",
+			"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+		},
+		MappedPort: "32400",
+	}, preferences)
+	assert.Equal(t, Preferences{
+		CustomConnections: []string{
+			"https://plex.example.com:443",
+			"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+		},
+		MappedPort: "32400",
+	}, updated)
+	// Other preferences need to be kept as they are
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `FriendlyName="MyPlexServer"`)
+	assert.Contains(t, string(data), `ProcessedMachineIdentifier="`+testMachineIdentifier+`"`)
+}
+
+func TestConfigFileBackend_SettingMissing(t *testing.T) {
+	// GIVEN
+	path := filepath.Join(t.TempDir(), "Preferences.xml")
+	err := os.WriteFile(path, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<Preferences FriendlyName="MyPlexServer"/>`), 0600)
+	require.NoError(t, err)
+	backend := NewConfigFileBackend(path)
+
+	// WHEN
+	_, machineIdentifierErr := backend.GetMachineIdentifier(context.Background())
+	_, preferencesErr := backend.GetPreferences(context.Background())
+
+	// THEN
+	assert.ErrorIs(t, machineIdentifierErr, plex.ErrSettingMissing)
+	assert.ErrorIs(t, preferencesErr, plex.ErrSettingMissing)
+}
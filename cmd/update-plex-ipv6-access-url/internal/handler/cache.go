@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+// CachingBackend caches the server's machine identifier. If refreshing an expired machine identifier fails,
+// the expired one is used instead.
+type CachingBackend struct {
+	Backend
+	cache *state.ServerCache
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+func NewCachingBackend(backend Backend, cache *state.ServerCache, ttl time.Duration) *CachingBackend {
+	return &CachingBackend{
+		Backend: backend,
+		cache:   cache,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func (b *CachingBackend) GetMachineIdentifier(ctx context.Context) (string, error) {
+	if b.cache.MachineIdentifier != "" && b.cache.IsFresh(b.ttl, b.now()) {
+		return b.cache.MachineIdentifier, nil
+	}
+
+	machineIdentifier, err := b.Backend.GetMachineIdentifier(ctx)
+	if err != nil {
+		if b.cache.MachineIdentifier != "" {
+			log.Warn().
+				Err(err).
+				Time("cachedAt", b.cache.CachedAt).
+				Msg("Failed to refresh machine identifier, using expired cached value")
+			return b.cache.MachineIdentifier, nil
+		}
+		return "", err
+	}
+
+	// The hostname belongs to the machine identifier, so it needs to be refreshed as well if that changed
+	if machineIdentifier != b.cache.MachineIdentifier {
+		b.cache.PlexDirectHostname = ""
+	}
+	b.cache.MachineIdentifier = machineIdentifier
+	b.cache.CachedAt = b.now()
+
+	return machineIdentifier, nil
+}
+
+// CachingHostnameProvider caches the server's plex.direct hostname. The hostname expires together with the machine
+// identifier (see CachingBackend). If refreshing an expired hostname fails, the expired one is used instead.
+type CachingHostnameProvider struct {
+	provider HostnameProvider
+	cache    *state.ServerCache
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+func NewCachingHostnameProvider(provider HostnameProvider, cache *state.ServerCache, ttl time.Duration) *CachingHostnameProvider {
+	return &CachingHostnameProvider{
+		provider: provider,
+		cache:    cache,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+func (p *CachingHostnameProvider) GetPlexDirectHostname(ctx context.Context, machineIdentifier string) (string, error) {
+	cached := p.cache.MachineIdentifier == machineIdentifier && p.cache.PlexDirectHostname != ""
+	if cached && p.cache.IsFresh(p.ttl, p.now()) {
+		return p.cache.PlexDirectHostname, nil
+	}
+
+	hostname, err := p.provider.GetPlexDirectHostname(ctx, machineIdentifier)
+	if err != nil {
+		if cached {
+			log.Warn().
+				Err(err).
+				Time("cachedAt", p.cache.CachedAt).
+				Msg("Failed to refresh plex.direct hostname, using expired cached value")
+			return p.cache.PlexDirectHostname, nil
+		}
+		return "", err
+	}
+
+	p.cache.MachineIdentifier = machineIdentifier
+	p.cache.PlexDirectHostname = hostname
+
+	return hostname, nil
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+type countingBackend struct {
+	fakeBackend
+	machineIdentifier string
+	err               error
+	calls             int
+}
+
+func (b *countingBackend) GetMachineIdentifier(ctx context.Context) (string, error) {
+	b.calls++
+	return b.machineIdentifier, b.err
+}
+
+type countingHostnameProvider struct {
+	fakeHostnameProvider
+	calls int
+}
+
+func (p *countingHostnameProvider) GetPlexDirectHostname(ctx context.Context, machineIdentifier string) (string, error) {
+	p.calls++
+	return p.fakeHostnameProvider.GetPlexDirectHostname(ctx, machineIdentifier)
+}
+
+func TestCachingBackend_GetMachineIdentifier(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ttl := time.Hour
+
+	tests := []struct {
+		name                  string
+		givenCache            state.ServerCache
+		givenMachineID        string
+		givenErr              error
+		wantMachineIdentifier string
+		wantCalls             int
+		wantCache             state.ServerCache
+		wantErrorContains     string
+	}{
+		{
+			name:                  "fetches and caches machine identifier if not cached",
+			givenMachineID:        testMachineIdentifier,
+			wantMachineIdentifier: testMachineIdentifier,
+			wantCalls:             1,
+			wantCache:             state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now},
+		},
+		{
+			name:                  "returns fresh cached machine identifier",
+			givenCache:            state.ServerCache{MachineIdentifier: testMachineIdentifier, PlexDirectHostname: testPlexDirectHostname, CachedAt: now.Add(-time.Minute)},
+			wantMachineIdentifier: testMachineIdentifier,
+			wantCalls:             0,
+			wantCache:             state.ServerCache{MachineIdentifier: testMachineIdentifier, PlexDirectHostname: testPlexDirectHostname, CachedAt: now.Add(-time.Minute)},
+		},
+		{
+			name:                  "refreshes expired machine identifier and resets hostname if identifier changed",
+			givenCache:            state.ServerCache{MachineIdentifier: "old-identifier", PlexDirectHostname: testPlexDirectHostname, CachedAt: now.Add(-2 * time.Hour)},
+			givenMachineID:        testMachineIdentifier,
+			wantMachineIdentifier: testMachineIdentifier,
+			wantCalls:             1,
+			wantCache:             state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now},
+		},
+		{
+			name:                  "returns expired machine identifier if refresh fails",
+			givenCache:            state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now.Add(-2 * time.Hour)},
+			givenErr:              errors.New("connection refused"),
+			wantMachineIdentifier: testMachineIdentifier,
+			wantCalls:             1,
+			wantCache:             state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now.Add(-2 * time.Hour)},
+		},
+		{
+			name:              "returns error if fetch fails and nothing is cached",
+			givenErr:          errors.New("connection refused"),
+			wantCalls:         1,
+			wantErrorContains: "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			backend := &countingBackend{machineIdentifier: tt.givenMachineID, err: tt.givenErr}
+			cache := tt.givenCache
+			b := NewCachingBackend(backend, &cache, ttl)
+			b.now = func() time.Time { return now }
+
+			// WHEN
+			machineIdentifier, err := b.GetMachineIdentifier(context.Background())
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantMachineIdentifier, machineIdentifier)
+				assert.Equal(t, tt.wantCache, cache)
+			}
+			assert.Equal(t, tt.wantCalls, backend.calls)
+		})
+	}
+}
+
+func TestCachingHostnameProvider_GetPlexDirectHostname(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ttl := time.Hour
+
+	tests := []struct {
+		name              string
+		givenCache        state.ServerCache
+		givenHostname     string
+		givenErr          error
+		wantHostname      string
+		wantCalls         int
+		wantErrorContains string
+	}{
+		{
+			name:          "fetches hostname if not cached",
+			givenCache:    state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now},
+			givenHostname: testPlexDirectHostname,
+			wantHostname:  testPlexDirectHostname,
+			wantCalls:     1,
+		},
+		{
+			name:         "returns fresh cached hostname",
+			givenCache:   state.ServerCache{MachineIdentifier: testMachineIdentifier, PlexDirectHostname: testPlexDirectHostname, CachedAt: now.Add(-time.Minute)},
+			wantHostname: testPlexDirectHostname,
+			wantCalls:    0,
+		},
+		{
+			name:          "does not return hostname cached for another machine identifier",
+			givenCache:    state.ServerCache{MachineIdentifier: "other-identifier", PlexDirectHostname: "other-server-id.plex.direct", CachedAt: now},
+			givenHostname: testPlexDirectHostname,
+			wantHostname:  testPlexDirectHostname,
+			wantCalls:     1,
+		},
+		{
+			name:         "returns expired hostname if refresh fails",
+			givenCache:   state.ServerCache{MachineIdentifier: testMachineIdentifier, PlexDirectHostname: testPlexDirectHostname, CachedAt: now.Add(-2 * time.Hour)},
+			givenErr:     errors.New("service unavailable"),
+			wantHostname: testPlexDirectHostname,
+			wantCalls:    1,
+		},
+		{
+			name:              "returns error if fetch fails and nothing is cached",
+			givenCache:        state.ServerCache{MachineIdentifier: testMachineIdentifier, CachedAt: now},
+			givenErr:          errors.New("service unavailable"),
+			wantCalls:         1,
+			wantErrorContains: "service unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			provider := &countingHostnameProvider{fakeHostnameProvider: fakeHostnameProvider{hostname: tt.givenHostname, err: tt.givenErr}}
+			cache := tt.givenCache
+			p := NewCachingHostnameProvider(provider, &cache, ttl)
+			p.now = func() time.Time { return now }
+
+			// WHEN
+			hostname, err := p.GetPlexDirectHostname(context.Background(), testMachineIdentifier)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantHostname, hostname)
+				assert.Equal(t, tt.wantHostname, cache.PlexDirectHostname)
+			}
+			assert.Equal(t, tt.wantCalls, provider.calls)
+		})
+	}
+}
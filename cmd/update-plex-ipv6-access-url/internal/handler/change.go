@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+)
+
+type UpdateResult string
+
+const (
+	UpdateResultUpdated   UpdateResult = "updated"
+	UpdateResultUnchanged UpdateResult = "unchanged"
+)
+
+func (r UpdateResult) String() string {
+	return string(r)
+}
+
+// Change describes an update of the custom access URLs (customConnections)
+type Change struct {
+	PlexDirectHostname string
+	Port               string
+
+	// Custom access URLs before/after the change
+	Current []string
+	Target  []string
+
+	// Custom access URLs contained in both Current and Target
+	Kept []string
+	// Custom access URLs only contained in Current (not including empty ones)
+	Removed []string
+	// Custom access URLs only contained in Target
+	Added []string
+
+	// Custom access URLs contained in Target which are managed by the tool
+	Managed []string
+}
+
+func newChange(plexDirectHostname, port string, current, target, managed []string) Change {
+	change := Change{
+		PlexDirectHostname: plexDirectHostname,
+		Port:               port,
+		Current:            current,
+		Target:             target,
+		Managed:            managed,
+	}
+
+	for _, c := range current {
+		if c == "" {
+			continue
+		}
+
+		if slices.Contains(target, c) {
+			change.Kept = append(change.Kept, c)
+		} else {
+			change.Removed = append(change.Removed, c)
+		}
+	}
+
+	for _, t := range target {
+		if !slices.Contains(current, t) {
+			change.Added = append(change.Added, t)
+		}
+	}
+
+	return change
+}
+
+// IsNoop returns whether the change would leave the custom access URLs as they are (including order and case)
+func (c Change) IsNoop() bool {
+	return strings.Join(c.Current, ",") == strings.Join(c.Target, ",")
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+// RetainAddrs determines which previously published addresses stay published next to the selected ones. Any address
+// which is no longer selected stays published for the grace period, but only for as long as it is still assigned to
+// the interface and valid.
+func RetainAddrs(interfaceAddrs []internal.InterfaceAddr, selected, published []netip.Addr, retiring []state.RetiringAddr, gracePeriod time.Duration, now time.Time) []state.RetiringAddr {
+	if gracePeriod <= 0 {
+		return nil
+	}
+
+	candidates := slices.Clone(retiring)
+	for _, addr := range published {
+		if !slices.ContainsFunc(candidates, func(r state.RetiringAddr) bool { return r.Addr == addr }) {
+			candidates = append(candidates, state.RetiringAddr{Addr: addr, Until: now.Add(gracePeriod)})
+		}
+	}
+
+	var retained []state.RetiringAddr
+	for _, c := range candidates {
+		// Selected addresses are published anyway
+		if slices.Contains(selected, c.Addr) {
+			continue
+		}
+
+		i := slices.IndexFunc(interfaceAddrs, func(a internal.InterfaceAddr) bool { return a.Addr == c.Addr })
+		if i == -1 {
+			continue
+		}
+
+		until := c.Until
+		if validLifetime := interfaceAddrs[i].ValidLifetime; validLifetime < until.Sub(now) {
+			until = now.Add(validLifetime)
+		}
+
+		if !until.After(now) {
+			continue
+		}
+
+		retained = append(retained, state.RetiringAddr{
+			Addr:  c.Addr,
+			Until: until,
+		})
+	}
+
+	return retained
+}
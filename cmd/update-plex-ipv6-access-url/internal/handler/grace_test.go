@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+func TestRetainAddrs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	oldAddr := netip.MustParseAddr("2001:db8:1::1")
+	newAddr := netip.MustParseAddr("2001:db8:2::1")
+	gracePeriod := time.Hour
+
+	tests := []struct {
+		name                string
+		givenInterfaceAddrs []internal.InterfaceAddr
+		givenSelected       []netip.Addr
+		givenPublished      []netip.Addr
+		givenRetiring       []state.RetiringAddr
+		givenGracePeriod    time.Duration
+		wantRetained        []state.RetiringAddr
+	}{
+		{
+			name: "retains previously published address for grace period",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: internal.InfiniteLifetime},
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{newAddr},
+			givenPublished:   []netip.Addr{oldAddr},
+			givenGracePeriod: gracePeriod,
+			wantRetained:     []state.RetiringAddr{{Addr: oldAddr, Until: now.Add(gracePeriod)}},
+		},
+		{
+			name: "limits grace period to valid lifetime",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: 10 * time.Minute},
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{newAddr},
+			givenPublished:   []netip.Addr{oldAddr},
+			givenGracePeriod: gracePeriod,
+			wantRetained:     []state.RetiringAddr{{Addr: oldAddr, Until: now.Add(10 * time.Minute)}},
+		},
+		{
+			name: "keeps end of grace period of retiring address",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: internal.InfiniteLifetime},
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{newAddr},
+			givenPublished:   []netip.Addr{newAddr},
+			givenRetiring:    []state.RetiringAddr{{Addr: oldAddr, Until: now.Add(5 * time.Minute)}},
+			givenGracePeriod: gracePeriod,
+			wantRetained:     []state.RetiringAddr{{Addr: oldAddr, Until: now.Add(5 * time.Minute)}},
+		},
+		{
+			name: "drops retiring address once grace period ended",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: internal.InfiniteLifetime},
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{newAddr},
+			givenPublished:   []netip.Addr{newAddr},
+			givenRetiring:    []state.RetiringAddr{{Addr: oldAddr, Until: now}},
+			givenGracePeriod: gracePeriod,
+		},
+		{
+			name: "drops retiring address no longer assigned to interface",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{newAddr},
+			givenPublished:   []netip.Addr{oldAddr},
+			givenGracePeriod: gracePeriod,
+		},
+		{
+			name: "drops retiring address which is selected again",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:    []netip.Addr{oldAddr},
+			givenPublished:   []netip.Addr{oldAddr},
+			givenRetiring:    []state.RetiringAddr{{Addr: oldAddr, Until: now.Add(5 * time.Minute)}},
+			givenGracePeriod: gracePeriod,
+		},
+		{
+			name: "retains nothing without grace period",
+			givenInterfaceAddrs: []internal.InterfaceAddr{
+				{Addr: oldAddr, ValidLifetime: internal.InfiniteLifetime},
+				{Addr: newAddr, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenSelected:  []netip.Addr{newAddr},
+			givenPublished: []netip.Addr{oldAddr},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			retained := RetainAddrs(tt.givenInterfaceAddrs, tt.givenSelected, tt.givenPublished, tt.givenRetiring, tt.givenGracePeriod, now)
+
+			// THEN
+			assert.Equal(t, tt.wantRetained, retained)
+		})
+	}
+}
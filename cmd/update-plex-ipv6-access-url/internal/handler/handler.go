@@ -1,136 +1,130 @@
 package handler
 
 import (
-	"fmt"
+	"context"
 	"net"
 	"net/netip"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
 )
 
 type ApiClient interface {
-	GetIdentity() (plex.IdentityDTO, error)
-	GetResources() (plex.ResourcesDTO, error)
-	GetPreferences() (plex.PreferencesDTO, error)
-	UpdateCustomConnections(customConnections string) error
+	GetIdentity(ctx context.Context) (plex.IdentityDTO, error)
+	GetPreferences(ctx context.Context) (plex.PreferencesDTO, error)
+	UpdateCustomConnections(ctx context.Context, customConnections string) error
 }
 
 type Handler struct {
-	localClient  ApiClient
-	remoteClient ApiClient
+	backend          Backend
+	hostnameProvider HostnameProvider
 }
 
-func NewHandler(localClient, remoteClient ApiClient) *Handler {
+func NewHandler(backend Backend, hostnameProvider HostnameProvider) *Handler {
 	return &Handler{
-		localClient:  localClient,
-		remoteClient: remoteClient,
+		backend:          backend,
+		hostnameProvider: hostnameProvider,
 	}
 }
 
-func (h *Handler) SelectAddrs(interfaceAddrs []netip.Addr, preference AddrPreference) ([]netip.Addr, error) {
-	switch preference {
-	case AddrPreferenceFirst:
-		return interfaceAddrs[:1], nil
-	case AddrPreferenceLast:
-		return interfaceAddrs[len(interfaceAddrs)-1:], nil
-	case AddrPreferenceAll:
-		return interfaceAddrs, nil
-	default:
-		return nil, fmt.Errorf("unkown address preference: %s", preference)
-	}
+// PlanOptions control how the IPv6 custom access URLs are built and where they are put
+type PlanOptions struct {
+	Capitalization IPv6URLCapitalization
+	Position       URLPosition
+	// Custom access URLs previously written by the tool, which are replaced even if they do not belong to the server
+	ManagedURLs []string
+	// Previously published addresses to keep publishing after the given ones (during their grace period)
+	RetainedAddrs []netip.Addr
 }
 
-func (h *Handler) UpdateIPv6CustomAccessURLs(addrs []netip.Addr, capitalization IPv6URLCapitalization) error {
-	identity, err := h.localClient.GetIdentity()
+func (h *Handler) UpdateIPv6CustomAccessURLs(ctx context.Context, addrs []netip.Addr, opts PlanOptions) (UpdateResult, error) {
+	change, err := h.PlanIPv6CustomAccessURLs(ctx, addrs, opts)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	plexDirectHostname, err := h.getPlexDirectHostname(identity.MachineIdentifier)
-	if err != nil {
-		return err
-	}
+	return h.ApplyChange(ctx, change)
+}
 
-	preferences, err := h.localClient.GetPreferences()
+// PlanIPv6CustomAccessURLs determines how the custom access URLs need to be changed in order to publish the given
+// addresses, without actually changing them
+func (h *Handler) PlanIPv6CustomAccessURLs(ctx context.Context, addrs []netip.Addr, opts PlanOptions) (Change, error) {
+	machineIdentifier, err := h.backend.GetMachineIdentifier(ctx)
 	if err != nil {
-		return err
+		return Change{}, err
 	}
 
-	currentAccessURLs, err := getCustomConnections(preferences)
+	plexDirectHostname, err := h.hostnameProvider.GetPlexDirectHostname(ctx, machineIdentifier)
 	if err != nil {
-		return err
+		return Change{}, err
 	}
 
-	mappedPort, err := getMappedPort(preferences)
+	preferences, err := h.backend.GetPreferences(ctx)
 	if err != nil {
-		return err
+		return Change{}, err
 	}
 
-	// Drop any existing IPv6 custom access urls (and empty ones) before adding a new one
-	targetAccessURLs := make([]string, 0, len(currentAccessURLs)+len(addrs))
+	currentAccessURLs := preferences.CustomConnections
+	mappedPort := preferences.MappedPort
+
+	// Drop any existing managed custom access urls (and empty ones), remembering where the first one was
+	otherAccessURLs := make([]string, 0, len(currentAccessURLs))
+	insertAt := -1
 	for _, c := range currentAccessURLs {
-		drop, err := isIPv6CustomAccessURL(c)
+		drop, err := isManagedCustomAccessURL(c, plexDirectHostname, mappedPort, opts.ManagedURLs)
 		if err != nil {
-			return err
+			return Change{}, err
 		}
 
-		if !drop && c != "" {
-			targetAccessURLs = append(targetAccessURLs, c)
+		if drop {
+			if insertAt == -1 {
+				insertAt = len(otherAccessURLs)
+			}
+			continue
 		}
-	}
 
-	for _, addr := range addrs {
-		targetAccessURLs = append(targetAccessURLs, buildIPv6CustomAccessURL(addr, plexDirectHostname, mappedPort, capitalization))
+		if c != "" {
+			otherAccessURLs = append(otherAccessURLs, c)
+		}
 	}
 
-	return h.localClient.UpdateCustomConnections(strings.Join(targetAccessURLs, ","))
-}
-
-func (h *Handler) getPlexDirectHostname(identifier string) (string, error) {
-	resources, err := h.remoteClient.GetResources()
-	if err != nil {
-		return "", err
+	switch opts.Position {
+	case URLPositionFront:
+		insertAt = 0
+	case URLPositionBack:
+		insertAt = len(otherAccessURLs)
+	default:
+		if insertAt == -1 {
+			insertAt = len(otherAccessURLs)
+		}
 	}
 
-	device, err := resources.GetDeviceByIdentifier(identifier)
-	if err != nil {
-		return "", err
+	ipv6AccessURLs := make([]string, 0, len(addrs)+len(opts.RetainedAddrs))
+	for _, addr := range slices.Concat(addrs, opts.RetainedAddrs) {
+		u := buildIPv6CustomAccessURL(addr, plexDirectHostname, mappedPort, opts.Capitalization)
+		if !slices.Contains(ipv6AccessURLs, u) {
+			ipv6AccessURLs = append(ipv6AccessURLs, u)
+		}
 	}
 
-	return device.GetPlexDirectHostname()
-}
+	targetAccessURLs := slices.Insert(otherAccessURLs, insertAt, ipv6AccessURLs...)
 
-func getCustomConnections(preferences plex.PreferencesDTO) ([]string, error) {
-	setting, err := preferences.GetSettingByID(plex.SettingIDCustomConnections)
-	if err != nil {
-		return nil, err
-	}
-
-	return strings.Split(setting.Value, ","), nil
+	return newChange(plexDirectHostname, mappedPort, currentAccessURLs, targetAccessURLs, ipv6AccessURLs), nil
 }
 
-func getMappedPort(preferences plex.PreferencesDTO) (string, error) {
-	manualPortMappingMode, err := preferences.GetSettingByID(plex.SettingIDManualPortMappingMode)
-	if err != nil {
-		return "", err
+// ApplyChange updates the custom access URLs according to the change, skipping the update if nothing changed
+func (h *Handler) ApplyChange(ctx context.Context, change Change) (UpdateResult, error) {
+	if change.IsNoop() {
+		return UpdateResultUnchanged, nil
 	}
 
-	var portSetting plex.SettingDTO
-	if manualPortMappingMode.IsEnabledBoolSetting() {
-		portSetting, err = preferences.GetSettingByID(plex.SettingIDManualPortMappingPort)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		portSetting, err = preferences.GetSettingByID(plex.SettingIDLastAutomaticMappedPort)
-		if err != nil {
-			return "", err
-		}
+	if err := h.backend.UpdateCustomConnections(ctx, strings.Join(change.Target, ",")); err != nil {
+		return "", err
 	}
 
-	return portSetting.Value, nil
+	return UpdateResultUpdated, nil
 }
 
 func buildIPv6CustomAccessURL(addr netip.Addr, plexDirectHostname, port string, capitalization IPv6URLCapitalization) string {
@@ -150,6 +144,28 @@ func buildIPv6CustomAccessURL(addr netip.Addr, plexDirectHostname, port string,
 	return u.String()
 }
 
+// isManagedCustomAccessURL returns whether the custom access URL is managed by the tool, meaning it was either written
+// by the tool before or is an IPv6 custom access URL for the server's plex.direct hostname and port
+func isManagedCustomAccessURL(customAccessURL, plexDirectHostname, port string, managedURLs []string) (bool, error) {
+	if slices.Contains(managedURLs, customAccessURL) {
+		return true, nil
+	}
+
+	isIPv6, err := isIPv6CustomAccessURL(customAccessURL)
+	if err != nil || !isIPv6 {
+		return false, err
+	}
+
+	u, err := url.Parse(customAccessURL)
+	if err != nil {
+		return false, err
+	}
+
+	// Strip the dashed IPv6 address, leaving [server-hash].plex.direct
+	_, hostname, _ := strings.Cut(u.Hostname(), ".")
+	return strings.EqualFold(hostname, plexDirectHostname) && u.Port() == port, nil
+}
+
 func isIPv6CustomAccessURL(customAccessURL string) (bool, error) {
 	u, err := url.Parse(customAccessURL)
 	if err != nil {
@@ -0,0 +1,748 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+const (
+	testMachineIdentifier  = "1142ed040a27acc36ea876e8362b28464c3d240d"
+	testPlexDirectHostname = "some-server-id.plex.direct"
+)
+
+type fakeBackend struct {
+	preferences       Preferences
+	customConnections *string
+}
+
+func (b *fakeBackend) GetMachineIdentifier(_ context.Context) (string, error) {
+	return testMachineIdentifier, nil
+}
+
+func (b *fakeBackend) GetPreferences(_ context.Context) (Preferences, error) {
+	return b.preferences, nil
+}
+
+func (b *fakeBackend) UpdateCustomConnections(_ context.Context, customConnections string) error {
+	b.customConnections = &customConnections
+	return nil
+}
+
+type fakeApiClient struct {
+	resources plex.ResourcesDTO
+}
+
+func (c *fakeApiClient) GetIdentity(_ context.Context) (plex.IdentityDTO, error) {
+	return plex.IdentityDTO{MachineIdentifier: testMachineIdentifier}, nil
+}
+
+func (c *fakeApiClient) GetResources(_ context.Context) (plex.ResourcesDTO, error) {
+	return c.resources, nil
+}
+
+func (c *fakeApiClient) GetPreferences(_ context.Context) (plex.PreferencesDTO, error) {
+	return plex.PreferencesDTO{}, nil
+}
+
+func (c *fakeApiClient) UpdateCustomConnections(_ context.Context, _ string) error {
+	return nil
+}
+
+func newFakeRemoteClient() *fakeApiClient {
+	return &fakeApiClient{
+		resources: plex.ResourcesDTO{
+			Devices: []plex.DeviceDTO{
+				{
+					Name:             "MyPlexServer",
+					ClientIdentifier: testMachineIdentifier,
+					Connections: []plex.ConnectionDTO{
+						{
+							Protocol: "https",
+							Address:  "192.168.1.2",
+							URI:      "https://192-168-1-2." + testPlexDirectHostname + ":32400",
+							Local:    "1",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestHandler_PlanIPv6CustomAccessURLs(t *testing.T) {
+	tests := []struct {
+		name                   string
+		givenCustomConnections []string
+		givenAddrs             []netip.Addr
+		givenPosition          URLPosition
+		givenManagedURLs       []string
+		givenRetainedAddrs     []netip.Addr
+		wantChange             Change
+	}{
+		{
+			name:                   "adds IPv6 custom access url to empty custom connections",
+			givenCustomConnections: []string{""},
+			givenAddrs:             []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current:            []string{""},
+				Target:             []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Added:              []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed:            []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "replaces existing IPv6 custom access url and keeps others",
+			givenCustomConnections: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+				"https://plex.example.com:443",
+			},
+			givenAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Target: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Kept:    []string{"https://plex.example.com:443"},
+				Removed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400"},
+				Added:   []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "replaces existing IPv6 custom access urls at position of first one",
+			givenCustomConnections: []string{
+				"https://plex.example.com:443",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+				"http://192.0.2.1:32400",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:32400",
+			},
+			givenAddrs:    []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::4")},
+			givenPosition: URLPositionKeep,
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"http://192.0.2.1:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:32400",
+				},
+				Target: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0004.some-server-id.plex.direct:32400",
+					"http://192.0.2.1:32400",
+				},
+				Kept: []string{"https://plex.example.com:443", "http://192.0.2.1:32400"},
+				Removed: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:32400",
+				},
+				Added: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0004.some-server-id.plex.direct:32400",
+				},
+				Managed: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0004.some-server-id.plex.direct:32400",
+				},
+			},
+		},
+		{
+			name:                   "appends IPv6 custom access url if there was none before",
+			givenCustomConnections: []string{"https://plex.example.com:443"},
+			givenAddrs:             []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			givenPosition:          URLPositionKeep,
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current:            []string{"https://plex.example.com:443"},
+				Target: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				},
+				Kept:    []string{"https://plex.example.com:443"},
+				Added:   []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "puts IPv6 custom access url in front",
+			givenCustomConnections: []string{
+				"https://plex.example.com:443",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+			},
+			givenAddrs:    []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			givenPosition: URLPositionFront,
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				},
+				Target: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Kept: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "puts IPv6 custom access url at the back",
+			givenCustomConnections: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				"https://plex.example.com:443",
+			},
+			givenAddrs:    []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			givenPosition: URLPositionBack,
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Target: []string{
+					"https://plex.example.com:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				},
+				Kept: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "keeps IPv6 custom access urls for other servers and ports",
+			givenCustomConnections: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.other-server-id.plex.direct:32400",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0005.some-server-id.plex.direct:32400",
+			},
+			givenAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.other-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0005.some-server-id.plex.direct:32400",
+				},
+				Target: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.other-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+				},
+				Kept: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.other-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+				},
+				Removed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0005.some-server-id.plex.direct:32400"},
+				Added:   []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "replaces custom access urls previously written by the tool",
+			givenCustomConnections: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.old-server-id.plex.direct:32400",
+				"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+			},
+			givenAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			givenManagedURLs: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.old-server-id.plex.direct:32400",
+			},
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.old-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+				},
+				Target: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443",
+				},
+				Kept:    []string{"https://2001-0db8-0000-0000-0000-0000-0000-0003.some-server-id.plex.direct:443"},
+				Removed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0002.old-server-id.plex.direct:32400"},
+				Added:   []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+		},
+		{
+			name: "keeps retained IPv6 custom access url after new one",
+			givenCustomConnections: []string{
+				"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+				"https://plex.example.com:443",
+			},
+			givenAddrs:         []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			givenRetainedAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::2")},
+			wantChange: Change{
+				PlexDirectHostname: testPlexDirectHostname,
+				Port:               "32400",
+				Current: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Target: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Kept: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+					"https://plex.example.com:443",
+				},
+				Added: []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Managed: []string{
+					"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400",
+					"https://2001-0db8-0000-0000-0000-0000-0000-0002.some-server-id.plex.direct:32400",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			backend := &fakeBackend{
+				preferences: Preferences{
+					CustomConnections: tt.givenCustomConnections,
+					MappedPort:        "32400",
+				},
+			}
+			h := NewHandler(backend, NewResourcesHostnameProvider(newFakeRemoteClient()))
+
+			// WHEN
+			change, err := h.PlanIPv6CustomAccessURLs(context.Background(), tt.givenAddrs, PlanOptions{
+				Capitalization: IPv6URLCapitalizationLower,
+				Position:       tt.givenPosition,
+				ManagedURLs:    tt.givenManagedURLs,
+				RetainedAddrs:  tt.givenRetainedAddrs,
+			})
+
+			// THEN
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantChange, change)
+			assert.Nil(t, backend.customConnections)
+		})
+	}
+}
+
+func TestHandler_ApplyChange(t *testing.T) {
+	tests := []struct {
+		name                  string
+		givenChange           Change
+		wantResult            UpdateResult
+		wantCustomConnections *string
+	}{
+		{
+			name: "updates custom connections if target differs from current",
+			givenChange: Change{
+				Current: []string{"https://plex.example.com:443"},
+				Target:  []string{"https://plex.example.com:443", "https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+			wantResult:            UpdateResultUpdated,
+			wantCustomConnections: ptr("https://plex.example.com:443,https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"),
+		},
+		{
+			name: "updates custom connections if only capitalization differs",
+			givenChange: Change{
+				Current: []string{"https://2001-0DB8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Target:  []string{"https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+			wantResult:            UpdateResultUpdated,
+			wantCustomConnections: ptr("https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"),
+		},
+		{
+			name: "skips update if target equals current",
+			givenChange: Change{
+				Current: []string{"https://plex.example.com:443", "https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+				Target:  []string{"https://plex.example.com:443", "https://2001-0db8-0000-0000-0000-0000-0000-0001.some-server-id.plex.direct:32400"},
+			},
+			wantResult: UpdateResultUnchanged,
+		},
+		{
+			name: "skips update if custom connections are empty",
+			givenChange: Change{
+				Current: []string{""},
+				Target:  []string{},
+			},
+			wantResult: UpdateResultUnchanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			backend := &fakeBackend{}
+			h := NewHandler(backend, NewResourcesHostnameProvider(newFakeRemoteClient()))
+
+			// WHEN
+			result, err := h.ApplyChange(context.Background(), tt.givenChange)
+
+			// THEN
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResult, result)
+			assert.Equal(t, tt.wantCustomConnections, backend.customConnections)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHandler_SelectAddrs(t *testing.T) {
+	slaac := internal.InterfaceAddr{Addr: netip.MustParseAddr("2001:db8:1::211:22ff:fe33:4455")}
+	static := internal.InterfaceAddr{Addr: netip.MustParseAddr("2001:db8:1::1234"), Flags: internal.AddrFlagPermanent}
+	otherPrefix := internal.InterfaceAddr{Addr: netip.MustParseAddr("2001:db8:2::1234")}
+
+	tests := []struct {
+		name              string
+		givenAddrs        []internal.InterfaceAddr
+		givenRules        AddrSelectionRules
+		wantAddrs         []netip.Addr
+		wantErrorContains string
+	}{
+		{
+			name:       "selects first address",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceFirst},
+			wantAddrs:  []netip.Addr{slaac.Addr},
+		},
+		{
+			name:       "selects last address",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceLast},
+			wantAddrs:  []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name:       "selects all addresses",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceAll},
+			wantAddrs:  []netip.Addr{slaac.Addr, static.Addr, otherPrefix.Addr},
+		},
+		{
+			name:       "selects only addresses contained in included prefixes",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:      AddrPreferenceAll,
+				IncludePrefixes: PrefixList{netip.MustParsePrefix("2001:db8:2::/48")},
+			},
+			wantAddrs: []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name:       "does not select addresses contained in excluded prefixes",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:      AddrPreferenceAll,
+				ExcludePrefixes: PrefixList{netip.MustParsePrefix("2001:db8:1::/48")},
+			},
+			wantAddrs: []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name:       "selects only addresses with interface identifier",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceAll,
+				InterfaceID: netip.MustParseAddr("::1234"),
+			},
+			wantAddrs: []netip.Addr{static.Addr, otherPrefix.Addr},
+		},
+		{
+			name:       "selects only static addresses if preferred",
+			givenAddrs: []internal.InterfaceAddr{slaac, static, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:   AddrPreferenceAll,
+				PreferStatic: true,
+			},
+			wantAddrs: []netip.Addr{static.Addr},
+		},
+		{
+			name:       "selects non-static addresses if static addresses are preferred but there are none",
+			givenAddrs: []internal.InterfaceAddr{slaac, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:   AddrPreferenceLast,
+				PreferStatic: true,
+			},
+			wantAddrs: []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name: "selects address with longest preferred lifetime",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, PreferredLifetime: time.Minute, ValidLifetime: time.Hour},
+				{Addr: otherPrefix.Addr, PreferredLifetime: time.Hour, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceLongestLifetime},
+			wantAddrs:  []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name: "selects address with longest valid lifetime if preferred lifetimes are equal",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, PreferredLifetime: time.Minute, ValidLifetime: 2 * time.Hour},
+				{Addr: otherPrefix.Addr, PreferredLifetime: time.Minute, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceLongestLifetime},
+			wantAddrs:  []netip.Addr{slaac.Addr},
+		},
+		{
+			name: "prefers address with infinite lifetime",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, PreferredLifetime: time.Hour, ValidLifetime: 2 * time.Hour},
+				{Addr: static.Addr, Flags: internal.AddrFlagPermanent, PreferredLifetime: internal.InfiniteLifetime, ValidLifetime: internal.InfiniteLifetime},
+			},
+			givenRules: AddrSelectionRules{Preference: AddrPreferenceLongestLifetime},
+			wantAddrs:  []netip.Addr{static.Addr},
+		},
+		{
+			name: "selects sticky address if still assigned",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, ValidLifetime: time.Hour},
+				{Addr: otherPrefix.Addr, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceFirst,
+				StickyAddrs: []netip.Addr{otherPrefix.Addr},
+			},
+			wantAddrs: []netip.Addr{otherPrefix.Addr},
+		},
+		{
+			name: "does not select sticky address if deprecated",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, ValidLifetime: time.Hour},
+				{Addr: otherPrefix.Addr, Flags: internal.AddrFlagDeprecated, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceFirst,
+				StickyAddrs: []netip.Addr{otherPrefix.Addr},
+			},
+			wantAddrs: []netip.Addr{slaac.Addr},
+		},
+		{
+			name: "does not select sticky address if no longer assigned",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, ValidLifetime: time.Hour},
+				{Addr: static.Addr, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceLast,
+				StickyAddrs: []netip.Addr{otherPrefix.Addr},
+			},
+			wantAddrs: []netip.Addr{static.Addr},
+		},
+		{
+			name: "does not select sticky address if excluded by selection rules",
+			givenAddrs: []internal.InterfaceAddr{
+				{Addr: slaac.Addr, ValidLifetime: time.Hour},
+				{Addr: otherPrefix.Addr, ValidLifetime: time.Hour},
+			},
+			givenRules: AddrSelectionRules{
+				Preference:      AddrPreferenceFirst,
+				ExcludePrefixes: PrefixList{netip.MustParsePrefix("2001:db8:2::/48")},
+				StickyAddrs:     []netip.Addr{otherPrefix.Addr},
+			},
+			wantAddrs: []netip.Addr{slaac.Addr},
+		},
+		{
+			name:       "selects all addresses regardless of sticky address",
+			givenAddrs: []internal.InterfaceAddr{slaac, otherPrefix},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceAll,
+				StickyAddrs: []netip.Addr{otherPrefix.Addr},
+			},
+			wantAddrs: []netip.Addr{slaac.Addr, otherPrefix.Addr},
+		},
+		{
+			name:       "returns error if no address matches selection rules",
+			givenAddrs: []internal.InterfaceAddr{slaac, static},
+			givenRules: AddrSelectionRules{
+				Preference:      AddrPreferenceFirst,
+				IncludePrefixes: PrefixList{netip.MustParsePrefix("2001:db8:2::/48")},
+			},
+			wantErrorContains: "none of the IPv6 addresses match the selection rules",
+		},
+		{
+			name:       "returns error for IPv4 interface identifier",
+			givenAddrs: []internal.InterfaceAddr{slaac},
+			givenRules: AddrSelectionRules{
+				Preference:  AddrPreferenceFirst,
+				InterfaceID: netip.MustParseAddr("10.0.0.1"),
+			},
+			wantErrorContains: "invalid interface identifier",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			h := NewHandler(&fakeBackend{}, NewResourcesHostnameProvider(newFakeRemoteClient()))
+
+			// WHEN
+			addrs, err := h.SelectAddrs(tt.givenAddrs, tt.givenRules)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantAddrs, addrs)
+			}
+		})
+	}
+}
+
+type fakeHostnameProvider struct {
+	hostname string
+	err      error
+}
+
+func (p *fakeHostnameProvider) GetPlexDirectHostname(_ context.Context, _ string) (string, error) {
+	return p.hostname, p.err
+}
+
+func TestFallbackHostnameProvider_GetPlexDirectHostname(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenProviders    []HostnameProvider
+		wantHostname      string
+		wantErrorContains string
+	}{
+		{
+			name: "returns hostname from first provider",
+			givenProviders: []HostnameProvider{
+				&fakeHostnameProvider{hostname: "some-server-id.plex.direct"},
+				&fakeHostnameProvider{hostname: "other-server-id.plex.direct"},
+			},
+			wantHostname: "some-server-id.plex.direct",
+		},
+		{
+			name: "falls back to next provider on error",
+			givenProviders: []HostnameProvider{
+				&fakeHostnameProvider{err: errors.New("connection refused")},
+				&fakeHostnameProvider{hostname: "other-server-id.plex.direct"},
+			},
+			wantHostname: "other-server-id.plex.direct",
+		},
+		{
+			name: "returns errors of all providers if all fail",
+			givenProviders: []HostnameProvider{
+				&fakeHostnameProvider{err: errors.New("connection refused")},
+				&fakeHostnameProvider{err: errors.New("service unavailable")},
+			},
+			wantErrorContains: "failed to determine plex.direct hostname: connection refused\nservice unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			provider := NewFallbackHostnameProvider(tt.givenProviders...)
+
+			// WHEN
+			hostname, err := provider.GetPlexDirectHostname(context.Background(), testMachineIdentifier)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantHostname, hostname)
+			}
+		})
+	}
+}
+
+func TestFallbackResourcesClient_GetResources(t *testing.T) {
+	someResources := plex.ResourcesDTO{Devices: []plex.DeviceDTO{{Name: "MyPlexServer"}}}
+	otherResources := plex.ResourcesDTO{Devices: []plex.DeviceDTO{{Name: "OtherPlexServer"}}}
+	resourcesClient := func(resources plex.ResourcesDTO, err error) ResourcesClient {
+		return ResourcesClientFunc(func(_ context.Context) (plex.ResourcesDTO, error) {
+			return resources, err
+		})
+	}
+
+	tests := []struct {
+		name              string
+		givenClients      []ResourcesClient
+		wantResources     plex.ResourcesDTO
+		wantErrorIs       error
+		wantErrorContains string
+	}{
+		{
+			name: "returns resources from first client",
+			givenClients: []ResourcesClient{
+				resourcesClient(someResources, nil),
+				resourcesClient(otherResources, nil),
+			},
+			wantResources: someResources,
+		},
+		{
+			name: "falls back to next client on error",
+			givenClients: []ResourcesClient{
+				resourcesClient(plex.ResourcesDTO{}, errors.New("status code 404")),
+				resourcesClient(otherResources, nil),
+			},
+			wantResources: otherResources,
+		},
+		{
+			name: "returns errors of all clients if all fail",
+			givenClients: []ResourcesClient{
+				resourcesClient(plex.ResourcesDTO{}, errors.New("status code 404")),
+				resourcesClient(plex.ResourcesDTO{}, errors.New("status code 410")),
+			},
+			wantErrorContains: "failed to fetch plex.tv resources: status code 404\nstatus code 410",
+		},
+		{
+			name: "does not fall back if token is rejected",
+			givenClients: []ResourcesClient{
+				resourcesClient(plex.ResourcesDTO{}, &plex.StatusError{StatusCode: 401, Status: "401 Unauthorized"}),
+				resourcesClient(otherResources, nil),
+			},
+			wantErrorIs: plex.ErrUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			client := NewFallbackResourcesClient(tt.givenClients...)
+
+			// WHEN
+			resources, err := client.GetResources(context.Background())
+
+			// THEN
+			if tt.wantErrorIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrorIs)
+			} else if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantResources, resources)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+// HostnameProvider provides the `[server-hash].plex.direct` hostname of a server
+type HostnameProvider interface {
+	GetPlexDirectHostname(ctx context.Context, machineIdentifier string) (string, error)
+}
+
+// ResourcesHostnameProvider determines the hostname from the server's connections listed in the plex.tv resources
+type ResourcesHostnameProvider struct {
+	client ResourcesClient
+}
+
+func NewResourcesHostnameProvider(client ResourcesClient) *ResourcesHostnameProvider {
+	return &ResourcesHostnameProvider{
+		client: client,
+	}
+}
+
+func (p *ResourcesHostnameProvider) GetPlexDirectHostname(ctx context.Context, machineIdentifier string) (string, error) {
+	resources, err := p.client.GetResources(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	device, err := resources.GetDeviceByIdentifier(machineIdentifier)
+	if err != nil {
+		return "", err
+	}
+
+	return device.GetPlexDirectHostname()
+}
+
+// CertificateHostnameProvider determines the hostname from the certificate presented by the server
+type CertificateHostnameProvider struct {
+	serverAddr string
+	timeout    int
+	tlsConfig  *tls.Config
+}
+
+func NewCertificateHostnameProvider(serverAddr string, timeout int, tlsConfig *tls.Config) *CertificateHostnameProvider {
+	return &CertificateHostnameProvider{
+		serverAddr: serverAddr,
+		timeout:    timeout,
+		tlsConfig:  tlsConfig,
+	}
+}
+
+func (p *CertificateHostnameProvider) GetPlexDirectHostname(ctx context.Context, _ string) (string, error) {
+	if p.serverAddr == "" {
+		return "", fmt.Errorf("cannot determine plex.direct hostname from certificate without server address")
+	}
+
+	return plex.GetCertificatePlexDirectHostname(ctx, p.serverAddr, p.timeout, p.tlsConfig)
+}
+
+// FallbackHostnameProvider tries each of the providers in order, returning the first hostname determined successfully
+type FallbackHostnameProvider struct {
+	providers []HostnameProvider
+}
+
+func NewFallbackHostnameProvider(providers ...HostnameProvider) *FallbackHostnameProvider {
+	return &FallbackHostnameProvider{
+		providers: providers,
+	}
+}
+
+func (p *FallbackHostnameProvider) GetPlexDirectHostname(ctx context.Context, machineIdentifier string) (string, error) {
+	errs := make([]error, 0, len(p.providers))
+	for _, provider := range p.providers {
+		hostname, err := provider.GetPlexDirectHostname(ctx, machineIdentifier)
+		if err == nil {
+			return hostname, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return "", fmt.Errorf("failed to determine plex.direct hostname: %w", errors.Join(errs...))
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"fmt"
+)
+
+type URLPosition string
+
+const (
+	// URLPositionKeep puts the IPv6 custom access URLs where the first previous one was (at the end if there was none)
+	URLPositionKeep URLPosition = "keep"
+	// URLPositionFront puts the IPv6 custom access URLs before any other custom access URLs
+	URLPositionFront URLPosition = "front"
+	// URLPositionBack puts the IPv6 custom access URLs after any other custom access URLs
+	URLPositionBack URLPosition = "back"
+)
+
+//goland:noinspection GoMixedReceiverTypes
+func (p URLPosition) String() string {
+	return string(p)
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (p *URLPosition) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*p = ""
+		return nil
+	}
+
+	s := string(text)
+	switch s {
+	case string(URLPositionKeep):
+		*p = URLPositionKeep
+	case string(URLPositionFront):
+		*p = URLPositionFront
+	case string(URLPositionBack):
+		*p = URLPositionBack
+	default:
+		return fmt.Errorf("invalid URL position: %s", s)
+	}
+
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (p URLPosition) MarshalText() (text []byte, err error) {
+	return []byte(p), nil
+}
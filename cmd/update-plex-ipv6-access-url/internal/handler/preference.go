@@ -10,6 +10,8 @@ const (
 	AddrPreferenceFirst AddrPreference = "first"
 	AddrPreferenceLast  AddrPreference = "last"
 	AddrPreferenceAll   AddrPreference = "all"
+	// AddrPreferenceLongestLifetime prefers the address with the longest remaining preferred (and valid) lifetime
+	AddrPreferenceLongestLifetime AddrPreference = "longest-lifetime"
 )
 
 //goland:noinspection GoMixedReceiverTypes
@@ -32,6 +34,8 @@ func (p *AddrPreference) UnmarshalText(text []byte) error {
 		*p = AddrPreferenceLast
 	case string(AddrPreferenceAll):
 		*p = AddrPreferenceAll
+	case string(AddrPreferenceLongestLifetime):
+		*p = AddrPreferenceLongestLifetime
 	default:
 		return fmt.Errorf("invalid address preference: %s", s)
 	}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// PrefixList is a list of IP prefixes (CIDRs), represented as comma-separated text
+type PrefixList []netip.Prefix
+
+// Contains returns whether any of the prefixes contains the given address
+func (l PrefixList) Contains(addr netip.Addr) bool {
+	for _, p := range l {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (l PrefixList) String() string {
+	prefixes := make([]string, 0, len(l))
+	for _, p := range l {
+		prefixes = append(prefixes, p.String())
+	}
+	return strings.Join(prefixes, ",")
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (l *PrefixList) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*l = nil
+		return nil
+	}
+
+	elems := strings.Split(string(text), ",")
+	prefixes := make(PrefixList, 0, len(elems))
+	for _, elem := range elems {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(elem))
+		if err != nil {
+			return fmt.Errorf("invalid prefix: %w", err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*l = prefixes
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (l PrefixList) MarshalText() (text []byte, err error) {
+	return []byte(l.String()), nil
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+// ResourcesClient provides the plex.tv resources (devices) of the account
+type ResourcesClient interface {
+	GetResources(ctx context.Context) (plex.ResourcesDTO, error)
+}
+
+// ResourcesClientFunc adapts a function to the ResourcesClient interface
+type ResourcesClientFunc func(ctx context.Context) (plex.ResourcesDTO, error)
+
+func (f ResourcesClientFunc) GetResources(ctx context.Context) (plex.ResourcesDTO, error) {
+	return f(ctx)
+}
+
+// FallbackResourcesClient tries each of the clients in order, returning the first resources fetched successfully
+type FallbackResourcesClient struct {
+	clients []ResourcesClient
+}
+
+func NewFallbackResourcesClient(clients ...ResourcesClient) *FallbackResourcesClient {
+	return &FallbackResourcesClient{
+		clients: clients,
+	}
+}
+
+func (c *FallbackResourcesClient) GetResources(ctx context.Context) (plex.ResourcesDTO, error) {
+	errs := make([]error, 0, len(c.clients))
+	for _, client := range c.clients {
+		resources, err := client.GetResources(ctx)
+		if err == nil {
+			return resources, nil
+		}
+		errs = append(errs, err)
+
+		// Do not fall back if the run was cancelled or the token was rejected (which the next API would do as well)
+		if ctx.Err() != nil || errors.Is(err, plex.ErrUnauthorized) {
+			break
+		}
+	}
+
+	return plex.ResourcesDTO{}, fmt.Errorf("failed to fetch plex.tv resources: %w", errors.Join(errs...))
+}
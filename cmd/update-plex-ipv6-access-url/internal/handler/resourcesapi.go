@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"fmt"
+)
+
+type ResourcesAPI string
+
+const (
+	// ResourcesAPIAuto tries the v2 (JSON) resources API first, falling back to the legacy (XML) resources API
+	ResourcesAPIAuto ResourcesAPI = "auto"
+	// ResourcesAPIV2 only uses the v2 (JSON) resources API
+	ResourcesAPIV2 ResourcesAPI = "v2"
+	// ResourcesAPILegacy only uses the legacy (XML) resources API
+	ResourcesAPILegacy ResourcesAPI = "legacy"
+)
+
+//goland:noinspection GoMixedReceiverTypes
+func (a ResourcesAPI) String() string {
+	return string(a)
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (a *ResourcesAPI) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*a = ""
+		return nil
+	}
+
+	v := string(text)
+	switch v {
+	case string(ResourcesAPIAuto):
+		*a = ResourcesAPIAuto
+	case string(ResourcesAPIV2):
+		*a = ResourcesAPIV2
+	case string(ResourcesAPILegacy):
+		*a = ResourcesAPILegacy
+	default:
+		return fmt.Errorf("invalid resources API: %s", v)
+	}
+
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (a ResourcesAPI) MarshalText() (text []byte, err error) {
+	return []byte(a), nil
+}
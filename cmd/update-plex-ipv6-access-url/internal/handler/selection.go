@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
+)
+
+// ErrNoMatchingAddrs indicates that none of the addresses on the interface can be used
+var ErrNoMatchingAddrs = errors.New("none of the IPv6 addresses match the selection rules")
+
+type AddrSelectionRules struct {
+	Preference AddrPreference
+	// Only consider addresses contained in any of these prefixes (all addresses if empty)
+	IncludePrefixes PrefixList
+	// Do not consider addresses contained in any of these prefixes
+	ExcludePrefixes PrefixList
+	// Only consider addresses with this interface identifier (lower 64 bits), e.g. ::1234 (any if not valid)
+	InterfaceID netip.Addr
+	// Only consider statically configured addresses, unless there are none
+	PreferStatic bool
+	// Keep using any of these (previously published) addresses for as long as it is still assigned and
+	// not deprecated, regardless of preference (does not apply to AddrPreferenceAll)
+	StickyAddrs []netip.Addr
+}
+
+func (h *Handler) SelectAddrs(interfaceAddrs []internal.InterfaceAddr, rules AddrSelectionRules) ([]netip.Addr, error) {
+	if rules.InterfaceID.IsValid() && !rules.InterfaceID.Is6() {
+		return nil, fmt.Errorf("invalid interface identifier: %s", rules.InterfaceID)
+	}
+
+	candidates := make([]internal.InterfaceAddr, 0, len(interfaceAddrs))
+	for _, a := range interfaceAddrs {
+		if len(rules.IncludePrefixes) > 0 && !rules.IncludePrefixes.Contains(a.Addr) {
+			continue
+		}
+
+		if rules.ExcludePrefixes.Contains(a.Addr) {
+			continue
+		}
+
+		if rules.InterfaceID.IsValid() && interfaceID(a.Addr) != interfaceID(rules.InterfaceID) {
+			continue
+		}
+
+		candidates = append(candidates, a)
+	}
+
+	if rules.PreferStatic {
+		candidates = preferStatic(candidates)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoMatchingAddrs
+	}
+
+	if rules.Preference != AddrPreferenceAll {
+		if sticky, ok := findStickyAddr(candidates, rules.StickyAddrs); ok {
+			return []netip.Addr{sticky.Addr}, nil
+		}
+	}
+
+	addrs := internal.Addrs(candidates)
+	switch rules.Preference {
+	case AddrPreferenceFirst:
+		return addrs[:1], nil
+	case AddrPreferenceLast:
+		return addrs[len(addrs)-1:], nil
+	case AddrPreferenceAll:
+		return addrs, nil
+	case AddrPreferenceLongestLifetime:
+		return []netip.Addr{longestLifetime(candidates).Addr}, nil
+	default:
+		return nil, fmt.Errorf("unkown address preference: %s", rules.Preference)
+	}
+}
+
+// findStickyAddr returns the first of the given interface addresses which is also a sticky address and still usable
+func findStickyAddr(interfaceAddrs []internal.InterfaceAddr, stickyAddrs []netip.Addr) (internal.InterfaceAddr, bool) {
+	for _, a := range interfaceAddrs {
+		if slices.Contains(stickyAddrs, a.Addr) && !a.Flags.Has(internal.AddrFlagDeprecated) && a.ValidLifetime > 0 {
+			return a, true
+		}
+	}
+
+	return internal.InterfaceAddr{}, false
+}
+
+// preferStatic returns only the statically configured addresses if there are any, else all addresses
+func preferStatic(interfaceAddrs []internal.InterfaceAddr) []internal.InterfaceAddr {
+	static := make([]internal.InterfaceAddr, 0, len(interfaceAddrs))
+	for _, a := range interfaceAddrs {
+		if a.Flags.Has(internal.AddrFlagPermanent) {
+			static = append(static, a)
+		}
+	}
+
+	if len(static) == 0 {
+		return interfaceAddrs
+	}
+
+	return static
+}
+
+// longestLifetime returns the address with the longest remaining preferred lifetime, using the valid lifetime
+// as a tiebreaker (first address wins if both are equal)
+func longestLifetime(interfaceAddrs []internal.InterfaceAddr) internal.InterfaceAddr {
+	longest := interfaceAddrs[0]
+	for _, a := range interfaceAddrs[1:] {
+		if a.PreferredLifetime > longest.PreferredLifetime ||
+			a.PreferredLifetime == longest.PreferredLifetime && a.ValidLifetime > longest.ValidLifetime {
+			longest = a
+		}
+	}
+	return longest
+}
+
+func interfaceID(addr netip.Addr) uint64 {
+	b := addr.As16()
+	return binary.BigEndian.Uint64(b[8:])
+}
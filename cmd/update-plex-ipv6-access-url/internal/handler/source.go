@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"fmt"
+)
+
+type HostnameSource string
+
+const (
+	// HostnameSourcePlexTV determines the plex.direct hostname via the plex.tv resources
+	HostnameSourcePlexTV HostnameSource = "plextv"
+	// HostnameSourceCertificate determines the plex.direct hostname via the Plex server's certificate
+	HostnameSourceCertificate HostnameSource = "certificate"
+	// HostnameSourceAuto tries the Plex server's certificate first, falling back to the plex.tv resources
+	HostnameSourceAuto HostnameSource = "auto"
+)
+
+//goland:noinspection GoMixedReceiverTypes
+func (s HostnameSource) String() string {
+	return string(s)
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (s *HostnameSource) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*s = ""
+		return nil
+	}
+
+	v := string(text)
+	switch v {
+	case string(HostnameSourcePlexTV):
+		*s = HostnameSourcePlexTV
+	case string(HostnameSourceCertificate):
+		*s = HostnameSourceCertificate
+	case string(HostnameSourceAuto):
+		*s = HostnameSourceAuto
+	default:
+		return fmt.Errorf("invalid hostname source: %s", v)
+	}
+
+	return nil
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (s HostnameSource) MarshalText() (text []byte, err error) {
+	return []byte(s), nil
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+// AccountClient provides the plex.tv account a token belongs to
+type AccountClient interface {
+	GetUser(ctx context.Context) (plex.UserDTO, error)
+}
+
+// TokenValidator checks whether the token can be used to update the server's preferences. Plex only allows the owner
+// of a server to change its preferences, so the token needs to belong to the owner (not to a user the server is
+// shared with or a managed user of the owner's Plex Home).
+type TokenValidator struct {
+	backend         Backend
+	accountClient   AccountClient
+	resourcesClient ResourcesClient
+	// In offline mode, the server is not contacted, so it cannot be checked whether it accepts the token
+	offline bool
+}
+
+func NewTokenValidator(backend Backend, accountClient AccountClient, resourcesClient ResourcesClient, offline bool) *TokenValidator {
+	return &TokenValidator{
+		backend:         backend,
+		accountClient:   accountClient,
+		resourcesClient: resourcesClient,
+		offline:         offline,
+	}
+}
+
+// Validate checks that the token is valid, belongs to the owner of the server and (unless in offline mode)
+// is accepted by the server
+func (v *TokenValidator) Validate(ctx context.Context) error {
+	user, err := v.accountClient.GetUser(ctx)
+	if err != nil {
+		if errors.Is(err, plex.ErrUnauthorized) {
+			return fmt.Errorf("plex.tv rejected the token, it is invalid or expired (obtain a new one, e.g. via the login command): %w", err)
+		}
+		return fmt.Errorf("failed to fetch plex.tv account of token: %w", err)
+	}
+
+	name := user.GetDisplayName()
+	if user.Restricted {
+		return fmt.Errorf("token belongs to managed user %s, which cannot change server preferences (use a token of the server owner instead)", name)
+	}
+
+	machineIdentifier, err := v.backend.GetMachineIdentifier(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get machine identifier of server: %w", err)
+	}
+
+	resources, err := v.resourcesClient.GetResources(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch plex.tv resources of account %s: %w", name, err)
+	}
+
+	device, err := resources.GetDeviceByIdentifier(machineIdentifier)
+	if err != nil {
+		return fmt.Errorf("server %s is not listed in the resources of account %s, make sure the server is signed in to the same account as the token: %w", machineIdentifier, name, err)
+	}
+
+	if !device.IsOwned() {
+		return fmt.Errorf("server %s (%s) is shared with account %s but not owned by it, which cannot change server preferences (use a token of the server owner instead)", device.Name, machineIdentifier, name)
+	}
+
+	if v.offline {
+		return nil
+	}
+
+	if _, err = v.backend.GetPreferences(ctx); err != nil {
+		if errors.Is(err, plex.ErrUnauthorized) {
+			return fmt.Errorf("server %s (%s) rejected the token of account %s, make sure the server is claimed and signed in: %w", device.Name, machineIdentifier, name, err)
+		}
+		return fmt.Errorf("failed to read preferences of server %s (%s): %w", device.Name, machineIdentifier, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+)
+
+type preferencesErrorBackend struct {
+	fakeBackend
+	err error
+}
+
+func (b *preferencesErrorBackend) GetPreferences(_ context.Context) (Preferences, error) {
+	return Preferences{}, b.err
+}
+
+type fakeAccountClient struct {
+	user plex.UserDTO
+	err  error
+}
+
+func (c *fakeAccountClient) GetUser(_ context.Context) (plex.UserDTO, error) {
+	return c.user, c.err
+}
+
+func TestTokenValidator_Validate(t *testing.T) {
+	unauthorized := &plex.StatusError{URL: "https://example.com", StatusCode: 401, Status: "401 Unauthorized"}
+	owner := plex.UserDTO{Username: "some-owner", Title: "some-owner"}
+	resources := func(owned string) ResourcesClient {
+		return ResourcesClientFunc(func(_ context.Context) (plex.ResourcesDTO, error) {
+			return plex.ResourcesDTO{
+				Devices: []plex.DeviceDTO{
+					{
+						Name:             "MyPlexServer",
+						ClientIdentifier: testMachineIdentifier,
+						Owned:            owned,
+					},
+				},
+			}, nil
+		})
+	}
+
+	tests := []struct {
+		name                 string
+		givenBackend         Backend
+		givenAccountClient   AccountClient
+		givenResourcesClient ResourcesClient
+		givenOffline         bool
+		wantErrorContains    string
+	}{
+		{
+			name:                 "accepts token of server owner",
+			givenBackend:         &fakeBackend{},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: resources("1"),
+		},
+		{
+			name:                 "rejects token rejected by plex.tv",
+			givenBackend:         &fakeBackend{},
+			givenAccountClient:   &fakeAccountClient{err: unauthorized},
+			givenResourcesClient: resources("1"),
+			wantErrorContains:    "plex.tv rejected the token, it is invalid or expired",
+		},
+		{
+			name:                 "rejects token of managed user",
+			givenBackend:         &fakeBackend{},
+			givenAccountClient:   &fakeAccountClient{user: plex.UserDTO{Title: "Kids", Restricted: true}},
+			givenResourcesClient: resources("1"),
+			wantErrorContains:    "token belongs to managed user Kids, which cannot change server preferences",
+		},
+		{
+			name:                 "rejects token of account the server is not listed for",
+			givenBackend:         &fakeBackend{},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: ResourcesClientFunc(func(_ context.Context) (plex.ResourcesDTO, error) { return plex.ResourcesDTO{}, nil }),
+			wantErrorContains:    "server " + testMachineIdentifier + " is not listed in the resources of account some-owner",
+		},
+		{
+			name:                 "rejects token of account the server is shared with",
+			givenBackend:         &fakeBackend{},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: resources("0"),
+			wantErrorContains:    "server MyPlexServer (" + testMachineIdentifier + ") is shared with account some-owner but not owned by it",
+		},
+		{
+			name:                 "rejects token rejected by server",
+			givenBackend:         &preferencesErrorBackend{err: unauthorized},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: resources("1"),
+			wantErrorContains:    "server MyPlexServer (" + testMachineIdentifier + ") rejected the token of account some-owner",
+		},
+		{
+			name:                 "does not check whether server accepts token in offline mode",
+			givenBackend:         &preferencesErrorBackend{err: unauthorized},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: resources("1"),
+			givenOffline:         true,
+		},
+		{
+			name:                 "returns error if preferences cannot be read",
+			givenBackend:         &preferencesErrorBackend{err: errors.New("connection refused")},
+			givenAccountClient:   &fakeAccountClient{user: owner},
+			givenResourcesClient: resources("1"),
+			wantErrorContains:    "failed to read preferences of server MyPlexServer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			v := NewTokenValidator(tt.givenBackend, tt.givenAccountClient, tt.givenResourcesClient, tt.givenOffline)
+
+			// WHEN
+			err := v.Validate(context.Background())
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
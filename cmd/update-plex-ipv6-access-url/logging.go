@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/logging"
+)
+
+const (
+	bytesPerMegabyte = 1024 * 1024
+)
+
+// redactor removes secrets (such as the Plex token) from anything written to logs or stdout
+var redactor = logging.NewRedactor()
+
+func setupLogger(cfg *config.Config) error {
+	// Keep stdout free for the run summary when using JSON output
+	var out io.Writer = os.Stdout
+	if cfg.OutputFormat == config.OutputFormatJSON {
+		out = os.Stderr
+	}
+
+	toFile := cfg.LogFile != ""
+	if toFile {
+		f, err := logging.OpenRotatingFile(cfg.LogFile, int64(cfg.LogFileMaxSize)*bytesPerMegabyte, cfg.LogFileMaxBackups)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+
+	out = redactor.Writer(out)
+
+	if cfg.LogFormat == config.LogFormatJSON {
+		log.Logger = zerolog.New(out).With().Timestamp().Logger()
+	} else {
+		var partsExclude []string
+		if !cfg.LogTimestamps {
+			partsExclude = append(partsExclude, zerolog.TimestampFieldName)
+		}
+		if !cfg.LogLevels {
+			partsExclude = append(partsExclude, zerolog.LevelFieldName)
+		}
+
+		log.Logger = zerolog.New(zerolog.ConsoleWriter{
+			Out: out,
+			// Color codes only make sense on a terminal
+			NoColor:      !cfg.ColorizeLogs || toFile,
+			PartsExclude: partsExclude,
+		}).With().Timestamp().Logger()
+	}
+
+	if cfg.Debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+const (
+	loginPollInterval = 2 * time.Second
+	// Used if plex.tv does not tell us when the PIN expires
+	loginDefaultExpiry = 15 * time.Minute
+)
+
+// login signs in to plex.tv using the PIN flow: the user authorizes a code shown by the tool at plex.tv/link,
+// after which the tool receives a token. The token is stored in the token store for later runs.
+func login(ctx context.Context, cfg *config.Config, s *state.State) error {
+	// Check before having the user sign in, since there is no way to store the token
+	if cfg.TokenStorePath == "" {
+		return fmt.Errorf("cannot determine where to store the token, use -token-store")
+	}
+
+	opts := []plex.ClientOption{
+		plex.WithRetryPolicy(cfg.PlexTVRetryPolicy()),
+		plex.WithClientIdentifier(clientIdentifier(s)),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		opts = append(opts, plex.WithDeviceName(hostname))
+	}
+	client := plex.NewApiClient(plex.AuthBaseURL, "", cfg.Timeout, opts...)
+
+	pin, err := client.CreatePin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create PIN: %w", err)
+	}
+
+	expiry := loginDefaultExpiry
+	if pin.ExpiresIn > 0 {
+		expiry = time.Duration(pin.ExpiresIn) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, expiry)
+	defer cancel()
+
+	fmt.Printf("To sign in, open %s and enter the code: %s\n", plex.LinkURL, pin.Code)
+
+	ticker := time.NewTicker(loginPollInterval)
+	defer ticker.Stop()
+
+	for !pin.IsAuthorized() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("PIN was not authorized in time: %w", ctx.Err())
+		case <-ticker.C:
+		}
+
+		pin, err = client.GetPin(ctx, pin.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check whether PIN was authorized: %w", err)
+		}
+	}
+
+	redactor.AddSecret(pin.AuthToken)
+	if err = state.WriteTokenStore(cfg.TokenStorePath, pin.AuthToken); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	// Persist the client identifier the token was issued to
+	writeState(cfg, s)
+
+	log.Info().
+		Str("path", cfg.TokenStorePath).
+		Msg("Successfully signed in to plex.tv, stored token")
+
+	return nil
+}
@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
 	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/handler"
 	"github.com/cetteup/update-plex-ipv6-access-url/internal"
 	"github.com/cetteup/update-plex-ipv6-access-url/internal/plex"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
 )
 
 const (
@@ -33,51 +45,192 @@ func main() {
 		os.Exit(0)
 	}
 
-	log.Logger = log.Output(zerolog.ConsoleWriter{
-		Out:          os.Stdout,
-		NoColor:      !cfg.ColorizeLogs,
-		PartsExclude: []string{"time", "level"},
-	})
+	redactor.AddSecret(cfg.Token)
+	if err := setupLogger(cfg); err != nil {
+		// Logger is not set up, so fall back to printing the error
+		_, _ = fmt.Fprintf(os.Stderr, "failed to set up logging: %s\n", err)
+		os.Exit(exitCodeError)
+	}
+
+	s := readState(cfg)
+
+	if cfg.Command == config.CommandLogin {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := login(ctx, cfg, s); err != nil {
+			log.Error().Err(err).Msg("Failed to sign in to plex.tv")
+			os.Exit(exitCodeForError(err))
+		}
+		return
+	}
 
-	if cfg.Debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	summary := newRunSummary(cfg)
+
+	if err := cfg.ReadValuesIfMissing(s); err != nil {
+		log.Error().Err(err).Msg("Failed to read missing config values")
+		exitWithError(cfg, summary, err)
+	}
+	redactor.AddSecret(cfg.Token)
+
+	// Also used for reading the plex.direct hostname from the server's certificate in offline mode
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to configure TLS for Plex server connection")
+		exitWithError(cfg, summary, err)
+	}
+
+	var backend handler.Backend
+	if cfg.Offline {
+		backend = handler.NewConfigFileBackend(cfg.ConfigPath)
 	} else {
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		backend = handler.NewApiBackend(plex.NewApiClient(
+			cfg.ServerAddr,
+			cfg.Token,
+			cfg.Timeout,
+			plex.WithRetryPolicy(cfg.RetryPolicy()),
+			plex.WithTLSConfig(tlsConfig),
+		))
+	}
+	remoteOpts := []plex.ClientOption{
+		plex.WithRetryPolicy(cfg.PlexTVRetryPolicy()),
+		plex.WithClientIdentifier(clientIdentifier(s)),
+	}
+	legacyClient := plex.NewApiClient(plex.BaseURL, cfg.Token, cfg.Timeout, remoteOpts...)
+	v2Client := plex.NewApiClient(plex.ClientsBaseURL, cfg.Token, cfg.Timeout, remoteOpts...)
+	resourcesClient := newResourcesClient(cfg, legacyClient, v2Client)
+	hostnameProvider := newHostnameProvider(cfg, resourcesClient, tlsConfig)
+	if cfg.CacheTTL > 0 {
+		cache := serverCache(cfg, s)
+		backend = handler.NewCachingBackend(backend, cache, cfg.CacheTTL)
+		hostnameProvider = handler.NewCachingHostnameProvider(hostnameProvider, cache, cfg.CacheTTL)
 	}
+	h := handler.NewHandler(backend, hostnameProvider)
 
-	if err := cfg.ReadValuesIfMissing(); err != nil {
-		log.Fatal().Err(err).Msg("Failed to read missing config values")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.ValidateToken {
+		accountClient := plex.NewApiClient(plex.AuthBaseURL, cfg.Token, cfg.Timeout, remoteOpts...)
+		validator := handler.NewTokenValidator(backend, accountClient, resourcesClient, cfg.Offline)
+		if err := validateToken(ctx, cfg, validator); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Plex token cannot be used to update custom access urls")
+			exitWithError(cfg, summary, err)
+		}
+	}
+
+	if cfg.Watch {
+		if err := watch(ctx, cfg, h, s); err != nil {
+			log.Error().
+				Err(err).
+				Str(logKeyInterfaceName, cfg.InterfaceName).
+				Msg("Failed to watch interface for IPv6 address changes")
+			exitWithError(cfg, summary, err)
+		}
+		return
 	}
 
-	interfaceAddrs, err := internal.GetGlobalUnicastIPv6AddrsByInterfaceName(cfg.InterfaceName)
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+
+	selectedAddrs, err := selectAddrs(cfg, h, s, summary)
 	if err != nil {
-		log.Fatal().
+		log.Error().
 			Err(err).
 			Str(logKeyInterfaceName, cfg.InterfaceName).
-			Msg("Failed to find global unicast IPv6 addresses on interface")
+			Msg("Failed to select IPv6 addresses to use")
+		exitWithError(cfg, summary, err)
+	}
+
+	result, err := update(ctx, cfg, h, selectedAddrs, s, summary)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed to update custom access urls")
+		exitWithError(cfg, summary, err)
+	}
+
+	summary.succeed(result)
+	summary.output(cfg)
+	os.Exit(exitCodeForResult(cfg, result))
+}
+
+// newTLSConfig builds the TLS config for the Plex server connection, warning about settings exposing the token
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	// The token is not sent to the server in offline mode
+	if u, err := url.Parse(cfg.ServerAddr); err == nil && u.Scheme == "http" && !isLoopbackHost(u.Hostname()) && !cfg.Offline {
+		log.Warn().
+			Str("address", cfg.ServerAddr).
+			Msg("Sending Plex token over plain HTTP, consider using HTTPS")
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Warn().Msg("Not verifying the Plex server's certificate, connection may be intercepted")
+	}
+
+	return plex.NewTLSConfig(cfg.TLSOptions())
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.IsLoopback()
+}
+
+func newResourcesClient(cfg *config.Config, legacyClient *plex.ApiClient, v2Client *plex.ApiClient) handler.ResourcesClient {
+	v2 := handler.ResourcesClientFunc(v2Client.GetResourcesV2)
+	switch cfg.ResourcesAPI {
+	case handler.ResourcesAPIV2:
+		return v2
+	case handler.ResourcesAPILegacy:
+		return legacyClient
+	default:
+		return handler.NewFallbackResourcesClient(v2, legacyClient)
+	}
+}
+
+func newHostnameProvider(cfg *config.Config, resourcesClient handler.ResourcesClient, tlsConfig *tls.Config) handler.HostnameProvider {
+	resourcesProvider := handler.NewResourcesHostnameProvider(resourcesClient)
+	certificateProvider := handler.NewCertificateHostnameProvider(cfg.ServerAddr, cfg.Timeout, tlsConfig)
+	switch cfg.HostnameSource {
+	case handler.HostnameSourceCertificate:
+		return certificateProvider
+	case handler.HostnameSourceAuto:
+		return handler.NewFallbackHostnameProvider(certificateProvider, resourcesProvider)
+	default:
+		return resourcesProvider
+	}
+}
+
+func selectAddrs(cfg *config.Config, h *handler.Handler, s *state.State, summary *runSummary) ([]netip.Addr, error) {
+	summary.Interface = cfg.InterfaceName
+
+	interfaceAddrs, err := internal.GetGlobalUnicastIPv6AddrsByInterfaceName(cfg.InterfaceName, cfg.ExcludedAddrFlags())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find global unicast IPv6 addresses on interface: %w", err)
 	}
 
 	if len(interfaceAddrs) == 0 {
-		log.Fatal().
-			Str(logKeyInterfaceName, cfg.InterfaceName).
-			Msg("No global unicast IPv6 address found on interface")
+		return nil, errNoAddrs
 	}
 
+	summary.CandidateAddrs = internal.Addrs(interfaceAddrs)
+
 	log.Info().
 		Str(logKeyInterfaceName, cfg.InterfaceName).
-		Interface("addresses", interfaceAddrs).
+		Interface("addresses", summary.CandidateAddrs).
 		Msg("Found IPv6 addresses on interface")
 
-	localClient := plex.NewApiClient(cfg.ServerAddr, cfg.Token, cfg.Timeout)
-	remoteClient := plex.NewApiClient(plex.BaseURL, cfg.Token, cfg.Timeout)
-	h := handler.NewHandler(localClient, remoteClient)
-
-	selectedAddrs, err := h.SelectAddrs(interfaceAddrs, cfg.AddrPreference)
+	selectedAddrs, err := h.SelectAddrs(interfaceAddrs, cfg.AddrSelectionRules(s))
 	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed to select IPv6 addresses to use")
+		return nil, err
 	}
+	summary.SelectedAddrs = selectedAddrs
 
 	if len(interfaceAddrs) > 1 {
 		log.Info().
@@ -86,12 +239,221 @@ func main() {
 			Msg("Selected IPv6 addresses")
 	}
 
-	err = h.UpdateIPv6CustomAccessURLs(selectedAddrs, cfg.Capitalization)
+	return selectedAddrs, nil
+}
+
+// update updates the custom access URLs in order to publish the given addresses. In dry run mode, the returned
+// result states whether the custom access URLs would have been updated.
+func update(ctx context.Context, cfg *config.Config, h *handler.Handler, addrs []netip.Addr, s *state.State, summary *runSummary) (handler.UpdateResult, error) {
+	retiring, err := retainAddrs(cfg, addrs, s)
+	if err != nil {
+		return "", err
+	}
+
+	opts := cfg.PlanOptions(s)
+	for _, r := range retiring {
+		opts.RetainedAddrs = append(opts.RetainedAddrs, r.Addr)
+	}
+	if len(opts.RetainedAddrs) > 0 {
+		summary.RetainedAddrs = opts.RetainedAddrs
+		log.Info().
+			Interface("addresses", opts.RetainedAddrs).
+			Msg("Keeping previous IPv6 addresses published during grace period")
+	}
+
+	change, err := h.PlanIPv6CustomAccessURLs(ctx, addrs, opts)
+	if err != nil {
+		return "", err
+	}
+	summary.recordChange(change)
+
+	if cfg.DryRun {
+		// The run summary contains the change when using JSON output
+		if cfg.OutputFormat != config.OutputFormatJSON {
+			printChange(change)
+		}
+		log.Info().Msg("Dry run, not updating IPv6 custom server access URLs")
+		if change.IsNoop() {
+			return handler.UpdateResultUnchanged, nil
+		}
+		return handler.UpdateResultUpdated, nil
+	}
+
+	result, err := h.ApplyChange(ctx, change)
 	if err != nil {
-		log.Fatal().
+		return "", err
+	}
+
+	s.PublishedAddrs = addrs
+	s.ManagedURLs = change.Managed
+	s.RetiringAddrs = retiring
+	writeState(cfg, s)
+
+	if result == handler.UpdateResultUnchanged {
+		log.Info().
+			Stringer("result", result).
+			Msg("IPv6 custom server access URLs are unchanged, skipping update")
+		return result, nil
+	}
+
+	log.Info().
+		Stringer("result", result).
+		Msg("Successfully updated IPv6 custom server access URLs")
+
+	if err = restartServerIfRequired(ctx, cfg); err != nil {
+		return "", fmt.Errorf("failed to restart Plex server: %w", err)
+	}
+
+	return result, nil
+}
+
+// retainAddrs determines which previously published addresses stay published next to the given ones
+// during the grace period
+func retainAddrs(cfg *config.Config, addrs []netip.Addr, s *state.State) ([]state.RetiringAddr, error) {
+	if cfg.GracePeriod <= 0 {
+		return nil, nil
+	}
+
+	// Consider all addresses, since previously published ones are usually deprecated (and thus excluded) by now
+	interfaceAddrs, err := internal.GetGlobalUnicastIPv6AddrsByInterfaceName(cfg.InterfaceName, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find global unicast IPv6 addresses on interface: %w", err)
+	}
+
+	return handler.RetainAddrs(interfaceAddrs, addrs, s.PublishedAddrs, s.RetiringAddrs, cfg.GracePeriod, time.Now()), nil
+}
+
+func validateToken(ctx context.Context, cfg *config.Config, validator *handler.TokenValidator) error {
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+
+	if err := validator.Validate(ctx); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Validated Plex token")
+
+	return nil
+}
+
+// withDeadline limits the context to the configured run deadline, if any
+func withDeadline(ctx context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	if cfg.Deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, cfg.Deadline)
+}
+
+// readState reads the state persisted by previous runs, falling back to an empty state if it cannot be read
+// or no state file path is set
+func readState(cfg *config.Config) *state.State {
+	if cfg.StateFilePath == "" {
+		log.Warn().Msg("No state file path set and no user cache directory available, continuing without persisted state")
+		return &state.State{}
+	}
+
+	s, err := state.ReadStateFile(cfg.StateFilePath)
+	if err != nil {
+		log.Warn().
 			Err(err).
-			Msg("Failed to update custom access urls")
+			Str("path", cfg.StateFilePath).
+			Msg("Failed to read state file, continuing without previous state")
+		return &state.State{}
+	}
+
+	return &s
+}
+
+func writeState(cfg *config.Config, s *state.State) {
+	if cfg.StateFilePath == "" {
+		return
+	}
+
+	if err := state.WriteStateFile(cfg.StateFilePath, *s); err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", cfg.StateFilePath).
+			Msg("Failed to write state file")
+	}
+}
+
+// clientIdentifier returns the identifier this tool uses towards plex.tv, generating one on first use. A generated
+// identifier is only kept in the state, which is persisted after a successful update or login (never in dry run mode).
+func clientIdentifier(s *state.State) string {
+	if s.ClientIdentifier == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		s.ClientIdentifier = hex.EncodeToString(b)
+	}
+
+	return s.ClientIdentifier
+}
+
+// serverCache returns the cached details of the configured server, discarding any details cached for another server
+func serverCache(cfg *config.Config, s *state.State) *state.ServerCache {
+	server := cfg.ServerAddr
+	if cfg.Offline {
+		server = cfg.ConfigPath
+	}
+
+	if s.Server == nil || s.Server.Server != server {
+		s.Server = &state.ServerCache{
+			Server: server,
+		}
+	}
+
+	// Do not keep using a hostname determined from a source which is no longer configured
+	hostnameSource := cfg.HostnameSource.String()
+	if cfg.HostnameSource != handler.HostnameSourceCertificate {
+		hostnameSource += "/" + cfg.ResourcesAPI.String()
+	}
+	if s.Server.HostnameSource != hostnameSource {
+		s.Server.PlexDirectHostname = ""
+		s.Server.HostnameSource = hostnameSource
+	}
+
+	return s.Server
+}
+
+// printChange prints a diff-like overview of the custom access URLs before/after the change
+func printChange(change handler.Change) {
+	fmt.Printf("customConnections (before): %s\n", strings.Join(change.Current, ","))
+	fmt.Printf("customConnections (after):  %s\n", strings.Join(change.Target, ","))
+	for _, c := range change.Removed {
+		fmt.Printf("- %s\n", c)
+	}
+	for _, c := range change.Kept {
+		fmt.Printf("  %s\n", c)
+	}
+	for _, c := range change.Added {
+		fmt.Printf("+ %s\n", c)
+	}
+}
+
+// restartServerIfRequired runs the configured restart command after the config file was updated in offline mode,
+// since Plex only reads it on startup
+func restartServerIfRequired(ctx context.Context, cfg *config.Config) error {
+	if !cfg.Offline || cfg.RestartCommand == "" {
+		return nil
+	}
+
+	log.Info().
+		Str("command", cfg.RestartCommand).
+		Msg("Restarting Plex server")
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", cfg.RestartCommand)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", cfg.RestartCommand)
+	}
+	cmd.Stdout = os.Stdout
+	// Keep stdout free for the run summary when using JSON output
+	if cfg.OutputFormat == config.OutputFormatJSON {
+		cmd.Stdout = os.Stderr
 	}
+	cmd.Stderr = os.Stderr
 
-	log.Info().Msg("Successfully updated IPv6 custom server access URLs")
+	return cmd.Run()
 }
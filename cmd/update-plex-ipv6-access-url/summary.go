@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/handler"
+)
+
+const (
+	actionFailed = "failed"
+)
+
+// runSummary is the machine-readable result of a run (or of a single update in watch mode)
+type runSummary struct {
+	Interface                 string        `json:"interface"`
+	CandidateAddrs            []netip.Addr  `json:"candidateAddresses"`
+	SelectedAddrs             []netip.Addr  `json:"selectedAddresses"`
+	RetainedAddrs             []netip.Addr  `json:"retainedAddresses"`
+	PlexDirectHostname        string        `json:"plexDirectHostname,omitempty"`
+	Port                      string        `json:"port,omitempty"`
+	PreviousCustomConnections []string      `json:"previousCustomConnections"`
+	NewCustomConnections      []string      `json:"newCustomConnections"`
+	DryRun                    bool          `json:"dryRun"`
+	Action                    string        `json:"action"`
+	Error                     *summaryError `json:"error,omitempty"`
+}
+
+type summaryError struct {
+	Message  string `json:"message"`
+	Kind     string `json:"kind"`
+	ExitCode int    `json:"exitCode"`
+}
+
+func newRunSummary(cfg *config.Config) *runSummary {
+	return &runSummary{
+		Interface:                 cfg.InterfaceName,
+		CandidateAddrs:            []netip.Addr{},
+		SelectedAddrs:             []netip.Addr{},
+		RetainedAddrs:             []netip.Addr{},
+		PreviousCustomConnections: []string{},
+		NewCustomConnections:      []string{},
+		DryRun:                    cfg.DryRun,
+	}
+}
+
+func (r *runSummary) recordChange(change handler.Change) {
+	r.PlexDirectHostname = change.PlexDirectHostname
+	r.Port = change.Port
+	r.PreviousCustomConnections = change.Current
+	r.NewCustomConnections = change.Target
+}
+
+func (r *runSummary) succeed(result handler.UpdateResult) {
+	r.Action = result.String()
+}
+
+func (r *runSummary) fail(err error) {
+	kind := classifyError(err)
+	r.Action = actionFailed
+	r.Error = &summaryError{
+		Message:  err.Error(),
+		Kind:     kind.name,
+		ExitCode: kind.exitCode,
+	}
+}
+
+// output prints the summary to stdout if JSON output is enabled
+func (r *runSummary) output(cfg *config.Config) {
+	if cfg.OutputFormat != config.OutputFormatJSON {
+		return
+	}
+
+	if err := json.NewEncoder(redactor.Writer(os.Stdout)).Encode(r); err != nil {
+		log.Error().Err(err).Msg("Failed to output run summary")
+	}
+}
+
+// exitWithError records the (already logged) error in the summary, outputs it and exits with the matching exit code
+func exitWithError(cfg *config.Config, summary *runSummary, err error) {
+	summary.fail(err)
+	summary.output(cfg)
+	os.Exit(exitCodeForError(err))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/config"
+	"github.com/cetteup/update-plex-ipv6-access-url/cmd/update-plex-ipv6-access-url/internal/handler"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal"
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+// watch updates the custom access URLs once and then again whenever the set of selected addresses changes,
+// until the context is cancelled. Address events are debounced, so a burst of changes (e.g. during a prefix
+// renumbering) results in a single update. With JSON output, a run summary is printed for each update.
+func watch(ctx context.Context, cfg *config.Config, h *handler.Handler, s *state.State) error {
+	watcher, err := internal.NewAddrWatcher(cfg.InterfaceName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	log.Info().
+		Str(logKeyInterfaceName, cfg.InterfaceName).
+		Msg("Watching interface for IPv6 address changes")
+
+	var published []netip.Addr
+	refresh := func() {
+		// Grace periods which ended require an update in order to remove the retired addresses. Since expired entries
+		// are only replaced by a successful update, drop them here, so failed or dry runs do not keep rescheduling them.
+		if pruneRetirements(s, time.Now()) {
+			published = nil
+		}
+
+		summary := newRunSummary(cfg)
+		selectedAddrs, err := selectAddrs(cfg, h, s, summary)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str(logKeyInterfaceName, cfg.InterfaceName).
+				Msg("Failed to select IPv6 addresses to use")
+			summary.fail(err)
+			summary.output(cfg)
+			return
+		}
+
+		if !shouldUpdate(published, selectedAddrs, s) {
+			log.Debug().
+				Interface("addresses", selectedAddrs).
+				Msg("Selected IPv6 addresses did not change, skipping update")
+			return
+		}
+
+		// Apply the deadline to each update, since the watch itself runs indefinitely
+		updateCtx, cancel := withDeadline(ctx, cfg)
+		defer cancel()
+
+		result, err := update(updateCtx, cfg, h, selectedAddrs, s, summary)
+		if err != nil {
+			// Keep previously published addresses, so the next change triggers another attempt
+			log.Error().
+				Err(err).
+				Msg("Failed to update custom access urls")
+			summary.fail(err)
+			summary.output(cfg)
+			return
+		}
+		summary.succeed(result)
+		summary.output(cfg)
+
+		published = sortedAddrs(selectedAddrs)
+	}
+
+	// Update again once the grace period of a previously published address ends, in order to remove it
+	retire := time.NewTimer(0)
+	retire.Stop()
+	defer retire.Stop()
+	scheduleRetirement := func() {
+		retire.Stop()
+		if until, ok := nextRetirement(s); ok {
+			retire.Reset(time.Until(until))
+		}
+	}
+
+	// Always update on start, since we do not know what is currently published
+	refresh()
+	scheduleRetirement()
+
+	debounce := time.NewTimer(cfg.WatchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopped watching interface for IPv6 address changes")
+			return nil
+		case _, ok := <-watcher.Events():
+			if !ok {
+				return watcher.Err()
+			}
+			log.Debug().
+				Str(logKeyInterfaceName, cfg.InterfaceName).
+				Dur("debounce", cfg.WatchDebounce).
+				Msg("IPv6 addresses on interface changed, waiting for further changes")
+			debounce.Reset(cfg.WatchDebounce)
+		case <-debounce.C:
+			refresh()
+			scheduleRetirement()
+		case <-retire.C:
+			log.Debug().Msg("Grace period of previous IPv6 address ended, updating")
+			refresh()
+			scheduleRetirement()
+		}
+	}
+}
+
+// shouldUpdate returns whether the custom access URLs need to be updated for the selected addresses, given the
+// (sorted) addresses published by the last successful update (nil if there was none)
+func shouldUpdate(published []netip.Addr, selected []netip.Addr, s *state.State) bool {
+	if published == nil {
+		return true
+	}
+
+	// Previously published addresses may have been removed from the interface, so always update during grace periods
+	if len(s.RetiringAddrs) > 0 {
+		return true
+	}
+
+	// The order in which addresses are reported may change without the addresses themselves changing
+	return !slices.Equal(published, sortedAddrs(selected))
+}
+
+func sortedAddrs(addrs []netip.Addr) []netip.Addr {
+	sorted := slices.Clone(addrs)
+	slices.SortFunc(sorted, func(a, b netip.Addr) int {
+		return a.Compare(b)
+	})
+	return sorted
+}
+
+// pruneRetirements removes previously published addresses whose grace period ended from the state,
+// returning whether any were removed
+func pruneRetirements(s *state.State, now time.Time) bool {
+	n := len(s.RetiringAddrs)
+	s.RetiringAddrs = slices.DeleteFunc(s.RetiringAddrs, func(r state.RetiringAddr) bool {
+		return !r.Until.After(now)
+	})
+	return len(s.RetiringAddrs) != n
+}
+
+// nextRetirement returns when the earliest grace period of any previously published address ends,
+// ignoring grace periods which already ended
+func nextRetirement(s *state.State) (time.Time, bool) {
+	now := time.Now()
+	var next time.Time
+	for _, r := range s.RetiringAddrs {
+		if !r.Until.After(now) {
+			continue
+		}
+		if next.IsZero() || r.Until.Before(next) {
+			next = r.Until
+		}
+	}
+	return next, !next.IsZero()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cetteup/update-plex-ipv6-access-url/internal/state"
+)
+
+func TestShouldUpdate(t *testing.T) {
+	addr1 := netip.MustParseAddr("2001:db8::1")
+	addr2 := netip.MustParseAddr("2001:db8::2")
+
+	tests := []struct {
+		name           string
+		givenPublished []netip.Addr
+		givenSelected  []netip.Addr
+		givenState     state.State
+		wantUpdate     bool
+	}{
+		{
+			name:          "updates if nothing was published yet",
+			givenSelected: []netip.Addr{addr1},
+			wantUpdate:    true,
+		},
+		{
+			name:           "skips update if selected addresses did not change",
+			givenPublished: []netip.Addr{addr1},
+			givenSelected:  []netip.Addr{addr1},
+			wantUpdate:     false,
+		},
+		{
+			name:           "skips update if only order of selected addresses changed",
+			givenPublished: []netip.Addr{addr1, addr2},
+			givenSelected:  []netip.Addr{addr2, addr1},
+			wantUpdate:     false,
+		},
+		{
+			name:           "updates if selected addresses changed",
+			givenPublished: []netip.Addr{addr1},
+			givenSelected:  []netip.Addr{addr2},
+			wantUpdate:     true,
+		},
+		{
+			name:           "updates if address was added",
+			givenPublished: []netip.Addr{addr1},
+			givenSelected:  []netip.Addr{addr1, addr2},
+			wantUpdate:     true,
+		},
+		{
+			name:           "updates during grace period even if selected addresses did not change",
+			givenPublished: []netip.Addr{addr2},
+			givenSelected:  []netip.Addr{addr2},
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{{Addr: addr1, Until: time.Now().Add(time.Hour)}},
+			},
+			wantUpdate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			update := shouldUpdate(tt.givenPublished, tt.givenSelected, &tt.givenState)
+
+			// THEN
+			assert.Equal(t, tt.wantUpdate, update)
+		})
+	}
+}
+
+func TestPruneRetirements(t *testing.T) {
+	addr1 := netip.MustParseAddr("2001:db8::1")
+	addr2 := netip.MustParseAddr("2001:db8::2")
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		givenState    state.State
+		givenSelected []netip.Addr
+		wantRetiring  []state.RetiringAddr
+		wantPruned    bool
+		wantUpdate    bool
+	}{
+		{
+			name: "removes address whose grace period ended",
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{
+					{Addr: addr1, Until: now.Add(-time.Minute)},
+				},
+			},
+			wantRetiring:  []state.RetiringAddr{},
+			wantPruned:    true,
+			givenSelected: []netip.Addr{addr2},
+			wantUpdate:    false,
+		},
+		{
+			name: "keeps address whose grace period did not end yet",
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{
+					{Addr: addr1, Until: now.Add(-time.Minute)},
+					{Addr: addr2, Until: now.Add(time.Hour)},
+				},
+			},
+			wantRetiring: []state.RetiringAddr{
+				{Addr: addr2, Until: now.Add(time.Hour)},
+			},
+			wantPruned:    true,
+			givenSelected: []netip.Addr{addr2},
+			wantUpdate:    true,
+		},
+		{
+			name:          "does nothing without retiring addresses",
+			givenSelected: []netip.Addr{addr2},
+			wantUpdate:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			pruned := pruneRetirements(&tt.givenState, now)
+
+			// THEN
+			assert.Equal(t, tt.wantPruned, pruned)
+			if tt.wantRetiring != nil {
+				assert.Equal(t, tt.wantRetiring, tt.givenState.RetiringAddrs)
+			} else {
+				assert.Empty(t, tt.givenState.RetiringAddrs)
+			}
+			// Expired entries must not force further updates once the selected addresses are published
+			assert.Equal(t, tt.wantUpdate, shouldUpdate([]netip.Addr{addr2}, tt.givenSelected, &tt.givenState))
+		})
+	}
+}
+
+func TestNextRetirement(t *testing.T) {
+	addr1 := netip.MustParseAddr("2001:db8::1")
+	addr2 := netip.MustParseAddr("2001:db8::2")
+	until := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		givenState state.State
+		wantNext   time.Time
+		wantOk     bool
+	}{
+		{
+			name: "returns earliest end of grace period",
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{
+					{Addr: addr1, Until: until.Add(time.Hour)},
+					{Addr: addr2, Until: until},
+				},
+			},
+			wantNext: until,
+			wantOk:   true,
+		},
+		{
+			name: "ignores grace period which already ended",
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{
+					{Addr: addr1, Until: time.Now().Add(-time.Minute)},
+					{Addr: addr2, Until: until},
+				},
+			},
+			wantNext: until,
+			wantOk:   true,
+		},
+		{
+			name: "returns nothing if all grace periods ended",
+			givenState: state.State{
+				RetiringAddrs: []state.RetiringAddr{
+					{Addr: addr1, Until: time.Now().Add(-time.Minute)},
+				},
+			},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			next, ok := nextRetirement(&tt.givenState)
+
+			// THEN
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.wantNext, next)
+			}
+		})
+	}
+}
@@ -1,41 +1,100 @@
 package internal
 
 import (
+	"math"
 	"net"
 	"net/netip"
+	"strings"
+	"time"
 )
 
-func GetGlobalUnicastIPv6AddrsByInterfaceName(name string) ([]netip.Addr, error) {
+// InfiniteLifetime is used for addresses which do not expire (and for addresses on platforms other than Linux,
+// where lifetimes are not available)
+const InfiniteLifetime = time.Duration(math.MaxInt64)
+
+// AddrFlags are the (kernel-reported) flags of an interface address. Flags are only available on Linux.
+type AddrFlags uint8
+
+const (
+	// AddrFlagTemporary marks temporary (privacy extension, RFC 4941) addresses
+	AddrFlagTemporary AddrFlags = 1 << iota
+	// AddrFlagDeprecated marks addresses whose preferred lifetime expired
+	AddrFlagDeprecated
+	// AddrFlagTentative marks addresses for which duplicate address detection did not complete yet
+	AddrFlagTentative
+	// AddrFlagDADFailed marks addresses for which duplicate address detection failed
+	AddrFlagDADFailed
+	// AddrFlagPermanent marks statically configured addresses (as opposed to ones configured via SLAAC/DHCPv6)
+	AddrFlagPermanent
+)
+
+var addrFlagNames = []struct {
+	flag AddrFlags
+	name string
+}{
+	{AddrFlagTemporary, "temporary"},
+	{AddrFlagDeprecated, "deprecated"},
+	{AddrFlagTentative, "tentative"},
+	{AddrFlagDADFailed, "dadfailed"},
+	{AddrFlagPermanent, "permanent"},
+}
+
+// Has returns whether any of the given flags is set
+func (f AddrFlags) Has(flag AddrFlags) bool {
+	return f&flag != 0
+}
+
+func (f AddrFlags) String() string {
+	names := make([]string, 0, len(addrFlagNames))
+	for _, n := range addrFlagNames {
+		if f.Has(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+type InterfaceAddr struct {
+	Addr  netip.Addr
+	Flags AddrFlags
+	// Remaining time until the address becomes deprecated
+	PreferredLifetime time.Duration
+	// Remaining time until the address becomes invalid (is removed)
+	ValidLifetime time.Duration
+}
+
+// Addrs returns the plain addresses of the given interface addresses
+func Addrs(interfaceAddrs []InterfaceAddr) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(interfaceAddrs))
+	for _, a := range interfaceAddrs {
+		addrs = append(addrs, a.Addr)
+	}
+	return addrs
+}
+
+// GetGlobalUnicastIPv6AddrsByInterfaceName returns the interface's global unicast IPv6 addresses,
+// excluding any addresses with one of the given flags
+func GetGlobalUnicastIPv6AddrsByInterfaceName(name string, excludeFlags AddrFlags) ([]InterfaceAddr, error) {
 	iface, err := net.InterfaceByName(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return getInterfaceIPv6GlobalUnicastAddrsByInterface(iface)
-}
-
-func getInterfaceIPv6GlobalUnicastAddrsByInterface(iface *net.Interface) ([]netip.Addr, error) {
-	addrs, err := iface.Addrs()
+	addrs, err := getInterfaceIPv6AddrsByInterface(iface)
 	if err != nil {
 		return nil, err
 	}
 
-	ipv6GlobalUnicastAddrs := make([]netip.Addr, 0, len(addrs))
+	ipv6GlobalUnicastAddrs := make([]InterfaceAddr, 0, len(addrs))
 	for _, addr := range addrs {
-		var ip net.IP
-		switch v := addr.(type) {
-		case *net.IPAddr:
-			ip = v.IP
-		case *net.IPNet:
-			ip = v.IP
-		default:
-			continue
-		}
-
-		if addrFromIP, ok := netip.AddrFromSlice(ip); ok && addrFromIP.Is6() && !addrFromIP.Is4In6() && addrFromIP.IsGlobalUnicast() && !addrFromIP.IsPrivate() {
-			ipv6GlobalUnicastAddrs = append(ipv6GlobalUnicastAddrs, addrFromIP)
+		if isIPv6GlobalUnicastAddr(addr.Addr) && !addr.Flags.Has(excludeFlags) {
+			ipv6GlobalUnicastAddrs = append(ipv6GlobalUnicastAddrs, addr)
 		}
 	}
 
 	return ipv6GlobalUnicastAddrs, nil
 }
+
+func isIPv6GlobalUnicastAddr(addr netip.Addr) bool {
+	return addr.Is6() && !addr.Is4In6() && addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
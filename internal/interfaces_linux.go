@@ -0,0 +1,132 @@
+//go:build linux
+
+package internal
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	// Extended (32-bit) address flags attribute (not defined by syscall)
+	ifaFlags = 0x8
+	// Size of struct ifa_cacheinfo (not defined by syscall)
+	sizeofIfaCacheinfo = 0x10
+	// Lifetime value used by the kernel for addresses which do not expire
+	ifaInfinityLifeTime = 0xFFFFFFFF
+)
+
+// getInterfaceIPv6AddrsByInterface returns the interface's IPv6 addresses including their flags, as reported by
+// the kernel via rtnetlink (the net package does not expose any address flags)
+func getInterfaceIPv6AddrsByInterface(iface *net.Interface) ([]InterfaceAddr, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaceAddrs := make([]InterfaceAddr, 0)
+	for _, m := range messages {
+		if m.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+
+		if m.Header.Type != syscall.RTM_NEWADDR || len(m.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+
+		// struct ifaddrmsg: family (u8), prefixlen (u8), flags (u8), scope (u8), index (u32)
+		family := m.Data[0]
+		index := binary.NativeEndian.Uint32(m.Data[4:8])
+		if family != syscall.AF_INET6 || int(index) != iface.Index {
+			continue
+		}
+
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, err
+		}
+
+		interfaceAddr, ok := parseInterfaceAddr(uint32(m.Data[2]), attrs)
+		if ok {
+			interfaceAddrs = append(interfaceAddrs, interfaceAddr)
+		}
+	}
+
+	return interfaceAddrs, nil
+}
+
+func parseInterfaceAddr(flags uint32, attrs []syscall.NetlinkRouteAttr) (InterfaceAddr, bool) {
+	var address, local netip.Addr
+	preferredLifetime, validLifetime := InfiniteLifetime, InfiniteLifetime
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case syscall.IFA_ADDRESS:
+			address, _ = netip.AddrFromSlice(attr.Value)
+		case syscall.IFA_LOCAL:
+			local, _ = netip.AddrFromSlice(attr.Value)
+		case ifaFlags:
+			// The ifaddrmsg flags field only holds the lower 8 bits, so prefer the extended flags if present
+			if len(attr.Value) >= 4 {
+				flags = binary.NativeEndian.Uint32(attr.Value[:4])
+			}
+		case syscall.IFA_CACHEINFO:
+			// struct ifa_cacheinfo: ifa_prefered (u32), ifa_valid (u32), cstamp (u32), tstamp (u32)
+			if len(attr.Value) >= sizeofIfaCacheinfo {
+				preferredLifetime = parseLifetime(binary.NativeEndian.Uint32(attr.Value[0:4]))
+				validLifetime = parseLifetime(binary.NativeEndian.Uint32(attr.Value[4:8]))
+			}
+		}
+	}
+
+	// IFA_LOCAL is only set for point-to-point addresses, in which case IFA_ADDRESS holds the peer's address
+	addr := address
+	if local.IsValid() {
+		addr = local
+	}
+
+	if !addr.IsValid() {
+		return InterfaceAddr{}, false
+	}
+
+	return InterfaceAddr{
+		Addr:              addr,
+		Flags:             parseAddrFlags(flags),
+		PreferredLifetime: preferredLifetime,
+		ValidLifetime:     validLifetime,
+	}, true
+}
+
+func parseLifetime(seconds uint32) time.Duration {
+	if seconds == ifaInfinityLifeTime {
+		return InfiniteLifetime
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func parseAddrFlags(flags uint32) AddrFlags {
+	var addrFlags AddrFlags
+	if flags&syscall.IFA_F_TEMPORARY != 0 {
+		addrFlags |= AddrFlagTemporary
+	}
+	if flags&syscall.IFA_F_DEPRECATED != 0 {
+		addrFlags |= AddrFlagDeprecated
+	}
+	if flags&syscall.IFA_F_TENTATIVE != 0 {
+		addrFlags |= AddrFlagTentative
+	}
+	if flags&syscall.IFA_F_DADFAILED != 0 {
+		addrFlags |= AddrFlagDADFailed
+	}
+	if flags&syscall.IFA_F_PERMANENT != 0 {
+		addrFlags |= AddrFlagPermanent
+	}
+	return addrFlags
+}
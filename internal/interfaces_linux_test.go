@@ -0,0 +1,128 @@
+//go:build linux
+
+package internal
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInterfaceAddr(t *testing.T) {
+	addr := netip.MustParseAddr("2001:db8::1")
+	peer := netip.MustParseAddr("2001:db8::2")
+
+	tests := []struct {
+		name          string
+		givenFlags    uint32
+		givenAttrs    []syscall.NetlinkRouteAttr
+		wantAddr      InterfaceAddr
+		wantAddrFound bool
+	}{
+		{
+			name:       "parses address and ifaddrmsg flags",
+			givenFlags: syscall.IFA_F_TEMPORARY | syscall.IFA_F_DEPRECATED,
+			givenAttrs: []syscall.NetlinkRouteAttr{
+				newRouteAttr(syscall.IFA_ADDRESS, addr.AsSlice()),
+			},
+			wantAddr: InterfaceAddr{
+				Addr:              addr,
+				Flags:             AddrFlagTemporary | AddrFlagDeprecated,
+				PreferredLifetime: InfiniteLifetime,
+				ValidLifetime:     InfiniteLifetime,
+			},
+			wantAddrFound: true,
+		},
+		{
+			name:       "prefers extended flags over ifaddrmsg flags",
+			givenFlags: syscall.IFA_F_TEMPORARY,
+			givenAttrs: []syscall.NetlinkRouteAttr{
+				newRouteAttr(syscall.IFA_ADDRESS, addr.AsSlice()),
+				newRouteAttr(ifaFlags, binary.NativeEndian.AppendUint32(nil, syscall.IFA_F_TENTATIVE|syscall.IFA_F_DADFAILED)),
+			},
+			wantAddr: InterfaceAddr{
+				Addr:              addr,
+				Flags:             AddrFlagTentative | AddrFlagDADFailed,
+				PreferredLifetime: InfiniteLifetime,
+				ValidLifetime:     InfiniteLifetime,
+			},
+			wantAddrFound: true,
+		},
+		{
+			name: "prefers local address over peer address",
+			givenAttrs: []syscall.NetlinkRouteAttr{
+				newRouteAttr(syscall.IFA_ADDRESS, peer.AsSlice()),
+				newRouteAttr(syscall.IFA_LOCAL, addr.AsSlice()),
+			},
+			wantAddr: InterfaceAddr{
+				Addr:              addr,
+				PreferredLifetime: InfiniteLifetime,
+				ValidLifetime:     InfiniteLifetime,
+			},
+			wantAddrFound: true,
+		},
+		{
+			name: "parses lifetimes",
+			givenAttrs: []syscall.NetlinkRouteAttr{
+				newRouteAttr(syscall.IFA_ADDRESS, addr.AsSlice()),
+				newRouteAttr(syscall.IFA_CACHEINFO, newCacheinfo(1800, 3600)),
+			},
+			wantAddr: InterfaceAddr{
+				Addr:              addr,
+				PreferredLifetime: 30 * time.Minute,
+				ValidLifetime:     time.Hour,
+			},
+			wantAddrFound: true,
+		},
+		{
+			name: "parses infinite lifetimes",
+			givenAttrs: []syscall.NetlinkRouteAttr{
+				newRouteAttr(syscall.IFA_ADDRESS, addr.AsSlice()),
+				newRouteAttr(syscall.IFA_CACHEINFO, newCacheinfo(ifaInfinityLifeTime, ifaInfinityLifeTime)),
+			},
+			wantAddr: InterfaceAddr{
+				Addr:              addr,
+				PreferredLifetime: InfiniteLifetime,
+				ValidLifetime:     InfiniteLifetime,
+			},
+			wantAddrFound: true,
+		},
+		{
+			name:          "ignores message without address",
+			givenFlags:    syscall.IFA_F_TEMPORARY,
+			wantAddrFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			interfaceAddr, ok := parseInterfaceAddr(tt.givenFlags, tt.givenAttrs)
+
+			// THEN
+			assert.Equal(t, tt.wantAddrFound, ok)
+			assert.Equal(t, tt.wantAddr, interfaceAddr)
+		})
+	}
+}
+
+func newRouteAttr(attrType uint16, value []byte) syscall.NetlinkRouteAttr {
+	return syscall.NetlinkRouteAttr{
+		Attr: syscall.RtAttr{
+			Len:  uint16(syscall.SizeofRtAttr + len(value)),
+			Type: attrType,
+		},
+		Value: value,
+	}
+}
+
+func newCacheinfo(preferred, valid uint32) []byte {
+	b := binary.NativeEndian.AppendUint32(nil, preferred)
+	b = binary.NativeEndian.AppendUint32(b, valid)
+	// cstamp and tstamp
+	return binary.NativeEndian.AppendUint32(binary.NativeEndian.AppendUint32(b, 0), 0)
+}
@@ -0,0 +1,43 @@
+//go:build !linux
+
+package internal
+
+import (
+	"net"
+	"net/netip"
+)
+
+func getInterfaceIPv6AddrsByInterface(iface *net.Interface) ([]InterfaceAddr, error) {
+	return getInterfaceAddrsByInterface(iface)
+}
+
+// getInterfaceAddrsByInterface returns the interface's addresses without any flags and with infinite lifetimes
+func getInterfaceAddrsByInterface(iface *net.Interface) ([]InterfaceAddr, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	interfaceAddrs := make([]InterfaceAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPAddr:
+			ip = v.IP
+		case *net.IPNet:
+			ip = v.IP
+		default:
+			continue
+		}
+
+		if addrFromIP, ok := netip.AddrFromSlice(ip); ok {
+			interfaceAddrs = append(interfaceAddrs, InterfaceAddr{
+				Addr:              addrFromIP,
+				PreferredLifetime: InfiniteLifetime,
+				ValidLifetime:     InfiniteLifetime,
+			})
+		}
+	}
+
+	return interfaceAddrs, nil
+}
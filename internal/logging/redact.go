@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	redacted = "[REDACTED]"
+)
+
+// Redactor replaces secrets in data with a placeholder. Secrets can be added at any time,
+// e.g. once a token has been read.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets [][]byte
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// AddSecret makes the redactor redact the given secret from any data from now on
+func (r *Redactor) AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.secrets {
+		if string(s) == secret {
+			return
+		}
+	}
+
+	r.secrets = append(r.secrets, []byte(secret))
+}
+
+func (r *Redactor) Redact(p []byte) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, secret := range r.secrets {
+		p = bytes.ReplaceAll(p, secret, []byte(redacted))
+	}
+
+	return p
+}
+
+// Writer returns a writer redacting any data before passing it on to w. Each write is expected to contain
+// complete messages (as is the case for log messages), so secrets are never split across writes.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{
+		w:        w,
+		redactor: r,
+	}
+}
+
+type redactingWriter struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.w.Write(w.redactor.Redact(p)); err != nil {
+		return 0, err
+	}
+
+	// Report the original length, since callers do not know about the redaction
+	return len(p), nil
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_Writer(t *testing.T) {
+	tests := []struct {
+		name         string
+		givenSecrets []string
+		givenData    string
+		wantData     string
+	}{
+		{
+			name:         "redacts all occurrences of secrets",
+			givenSecrets: []string{"some-token", "other-token"},
+			givenData:    `request to https://example.com/?X-Plex-Token=some-token failed (token: some-token, other-token)`,
+			wantData:     `request to https://example.com/?X-Plex-Token=[REDACTED] failed (token: [REDACTED], [REDACTED])`,
+		},
+		{
+			name:         "passes data without secrets unchanged",
+			givenSecrets: []string{"some-token"},
+			givenData:    "nothing to see here",
+			wantData:     "nothing to see here",
+		},
+		{
+			name:         "ignores empty secrets",
+			givenSecrets: []string{""},
+			givenData:    "nothing to see here",
+			wantData:     "nothing to see here",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			var buf bytes.Buffer
+			r := NewRedactor()
+			for _, secret := range tt.givenSecrets {
+				r.AddSecret(secret)
+			}
+			w := r.Writer(&buf)
+
+			// WHEN
+			n, err := w.Write([]byte(tt.givenData))
+
+			// THEN
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.givenData), n)
+			assert.Equal(t, tt.wantData, buf.String())
+		})
+	}
+}
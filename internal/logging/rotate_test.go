@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile_Write(t *testing.T) {
+	tests := []struct {
+		name             string
+		givenExisting    string
+		givenMaxSize     int64
+		givenMaxBackups  int
+		givenWrites      []string
+		wantFiles        map[string]string
+		wantMissingFiles []string
+	}{
+		{
+			name:            "does not rotate below maximum size",
+			givenMaxSize:    10,
+			givenMaxBackups: 2,
+			givenWrites:     []string{"abc\n", "def\n"},
+			wantFiles: map[string]string{
+				"app.log": "abc\ndef\n",
+			},
+			wantMissingFiles: []string{"app.log.1"},
+		},
+		{
+			name:            "rotates once maximum size would be exceeded",
+			givenMaxSize:    10,
+			givenMaxBackups: 2,
+			givenWrites:     []string{"abc\n", "def\n", "ghi\n", "jkl\n", "mno\n", "pqr\n", "stu\n"},
+			wantFiles: map[string]string{
+				"app.log":   "stu\n",
+				"app.log.1": "mno\npqr\n",
+				"app.log.2": "ghi\njkl\n",
+			},
+			wantMissingFiles: []string{"app.log.3"},
+		},
+		{
+			name:            "takes size of existing file into account",
+			givenExisting:   "12345678\n",
+			givenMaxSize:    10,
+			givenMaxBackups: 1,
+			givenWrites:     []string{"abc\n"},
+			wantFiles: map[string]string{
+				"app.log":   "abc\n",
+				"app.log.1": "12345678\n",
+			},
+		},
+		{
+			name:            "removes file without backups",
+			givenMaxSize:    4,
+			givenMaxBackups: 0,
+			givenWrites:     []string{"abc\n", "def\n"},
+			wantFiles: map[string]string{
+				"app.log": "def\n",
+			},
+			wantMissingFiles: []string{"app.log.1"},
+		},
+		{
+			name:            "writes messages exceeding maximum size to empty file",
+			givenMaxSize:    2,
+			givenMaxBackups: 1,
+			givenWrites:     []string{"abcdef\n"},
+			wantFiles: map[string]string{
+				"app.log": "abcdef\n",
+			},
+			wantMissingFiles: []string{"app.log.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			if tt.givenExisting != "" {
+				require.NoError(t, os.WriteFile(path, []byte(tt.givenExisting), 0600))
+			}
+
+			f, err := OpenRotatingFile(path, tt.givenMaxSize, tt.givenMaxBackups)
+			require.NoError(t, err)
+
+			// WHEN
+			for _, w := range tt.givenWrites {
+				_, err = f.Write([]byte(w))
+				require.NoError(t, err)
+			}
+			require.NoError(t, f.Close())
+
+			// THEN
+			for name, want := range tt.wantFiles {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				assert.Equal(t, want, string(data), name)
+			}
+			for _, name := range tt.wantMissingFiles {
+				assert.NoFileExists(t, filepath.Join(dir, name))
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package plex
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,14 +15,23 @@ import (
 
 const (
 	BaseURL                   = "https://plex.tv/api"
+	ClientsBaseURL            = "https://clients.plex.tv/api/v2"
+	AuthBaseURL               = "https://plex.tv/api/v2"
 	identityEndpoint          = "/identity"
 	resourcesEndpoint         = "/resources"
 	preferencesEndpoint       = "/:/prefs"
 	headerKeyToken            = "X-Plex-Token"
+	headerKeyClientIdentifier = "X-Plex-Client-Identifier"
+	headerKeyProduct          = "X-Plex-Product"
+	headerKeyDeviceName       = "X-Plex-Device-Name"
+	headerKeyAccept           = "Accept"
 	queryKeyIncludeHttps      = "includeHttps"
 	queryKeyIncludeIPv6       = "includeIPv6"
 	queryKeyCustomConnections = "customConnections"
 
+	product         = "update-plex-ipv6-access-url"
+	contentTypeJSON = "application/json"
+
 	SettingIDCustomConnections       = "customConnections"
 	SettingIDManualPortMappingMode   = "ManualPortMappingMode"
 	SettingIDManualPortMappingPort   = "ManualPortMappingPort"
@@ -45,16 +55,22 @@ func (r ResourcesDTO) GetDeviceByIdentifier(clientIdentifier string) (DeviceDTO,
 		}
 	}
 
-	return DeviceDTO{}, fmt.Errorf("no such device: %s", clientIdentifier)
+	return DeviceDTO{}, fmt.Errorf("%w: %s", ErrDeviceNotFound, clientIdentifier)
 }
 
 type DeviceDTO struct {
 	Name             string          `xml:"name,attr"`
 	Product          string          `xml:"product,attr"`
 	ClientIdentifier string          `xml:"clientIdentifier,attr"`
+	Owned            string          `xml:"owned,attr"`
 	Connections      []ConnectionDTO `xml:"Connection"`
 }
 
+// IsOwned returns whether the device is owned by the account (rather than shared with it)
+func (d DeviceDTO) IsOwned() bool {
+	return d.Owned == plexTrue
+}
+
 func (d DeviceDTO) GetLocalConnection() (ConnectionDTO, error) {
 	return d.getConnectionByLocation(plexTrue)
 }
@@ -73,21 +89,92 @@ func (d DeviceDTO) getConnectionByLocation(local string) (ConnectionDTO, error)
 	return ConnectionDTO{}, fmt.Errorf("no location connection found for device: %s", d.Name)
 }
 
+// GetPlexDirectHostname returns the device's `[server-hash].plex.direct` hostname, based on its connections
 func (d DeviceDTO) GetPlexDirectHostname() (string, error) {
+	var hostname string
 	for _, c := range d.Connections {
-		if strings.Contains(c.URI, ".plex.direct") {
-			u, err := url.Parse(c.URI)
-			if err != nil {
-				return "", err
-			}
-
-			// Remove `dashed-ipv6-address.` prefix to only return `[server-id].plex.direct`
-			hostname := strings.TrimPrefix(u.Hostname(), fmt.Sprintf("%s.", strings.ReplaceAll(c.Address, ".", "-")))
-			return hostname, nil
+		u, err := url.Parse(c.URI)
+		if err != nil {
+			return "", err
+		}
+
+		h, ok := parsePlexDirectHostname(u.Hostname())
+		if !ok {
+			continue
+		}
+
+		if hostname != "" && h != hostname {
+			return "", fmt.Errorf("connections of device %s use different .plex.direct hostnames: %s, %s", d.Name, hostname, h)
 		}
+		hostname = h
+	}
+
+	if hostname == "" {
+		return "", fmt.Errorf("no .plex.direct hostname found for device: %s", d.Name)
+	}
+
+	return hostname, nil
+}
+
+// parsePlexDirectHostname extracts the `[server-hash].plex.direct` hostname from a connection hostname in format
+// `[dashed-ip-address].[server-hash].plex.direct`, regardless of whether the IP address is an IPv4 or IPv6 address
+func parsePlexDirectHostname(hostname string) (string, bool) {
+	labels := strings.Split(strings.ToLower(hostname), ".")
+	if len(labels) != 4 || labels[2] != "plex" || labels[3] != "direct" || labels[0] == "" || labels[1] == "" {
+		return "", false
+	}
+
+	return strings.Join(labels[1:], "."), true
+}
+
+// resourceV2DTO is a device as returned by the v2 (JSON) resources API
+type resourceV2DTO struct {
+	Name             string            `json:"name"`
+	Product          string            `json:"product"`
+	ClientIdentifier string            `json:"clientIdentifier"`
+	Owned            bool              `json:"owned"`
+	Connections      []connectionV2DTO `json:"connections"`
+}
+
+func (r resourceV2DTO) toDeviceDTO() DeviceDTO {
+	connections := make([]ConnectionDTO, 0, len(r.Connections))
+	for _, c := range r.Connections {
+		connections = append(connections, c.toConnectionDTO())
+	}
+
+	owned := plexFalse
+	if r.Owned {
+		owned = plexTrue
 	}
 
-	return "", fmt.Errorf("no .plex.direct hostname found for device: %s", d.Name)
+	return DeviceDTO{
+		Name:             r.Name,
+		Product:          r.Product,
+		ClientIdentifier: r.ClientIdentifier,
+		Owned:            owned,
+		Connections:      connections,
+	}
+}
+
+type connectionV2DTO struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	URI      string `json:"uri"`
+	Local    bool   `json:"local"`
+}
+
+func (c connectionV2DTO) toConnectionDTO() ConnectionDTO {
+	local := plexFalse
+	if c.Local {
+		local = plexTrue
+	}
+
+	return ConnectionDTO{
+		Protocol: c.Protocol,
+		Address:  c.Address,
+		URI:      c.URI,
+		Local:    local,
+	}
 }
 
 type ConnectionDTO struct {
@@ -108,7 +195,7 @@ func (p PreferencesDTO) GetSettingByID(id string) (SettingDTO, error) {
 		}
 	}
 
-	return SettingDTO{}, fmt.Errorf("no such setting: %s", id)
+	return SettingDTO{}, fmt.Errorf("%w: %s", ErrSettingMissing, id)
 }
 
 type SettingDTO struct {
@@ -122,22 +209,56 @@ func (s SettingDTO) IsEnabledBoolSetting() bool {
 }
 
 type ApiClient struct {
-	client  http.Client
-	baseURL string
-	token   string
+	client           http.Client
+	baseURL          string
+	token            string
+	clientIdentifier string
+	deviceName       string
+	retryPolicy      RetryPolicy
+}
+
+type ClientOption func(c *ApiClient)
+
+// WithRetryPolicy makes the client retry failed requests according to the given policy
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *ApiClient) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithClientIdentifier makes the client identify itself with the given (unique, stable) identifier,
+// which some plex.tv APIs require
+func WithClientIdentifier(clientIdentifier string) ClientOption {
+	return func(c *ApiClient) {
+		c.clientIdentifier = clientIdentifier
+	}
 }
 
-func NewApiClient(baseURL string, token string, timeout int) *ApiClient {
-	return &ApiClient{
+// WithDeviceName makes the client send the given device name, under which it is listed in the account's devices
+func WithDeviceName(deviceName string) ClientOption {
+	return func(c *ApiClient) {
+		c.deviceName = deviceName
+	}
+}
+
+func NewApiClient(baseURL string, token string, timeout int, opts ...ClientOption) *ApiClient {
+	c := &ApiClient{
 		client: http.Client{
 			Timeout: time.Second * time.Duration(timeout),
 		},
-		baseURL: baseURL,
-		token:   token,
+		baseURL:     baseURL,
+		token:       token,
+		retryPolicy: NoRetryPolicy,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
-func (c *ApiClient) GetIdentity() (IdentityDTO, error) {
+func (c *ApiClient) GetIdentity(ctx context.Context) (IdentityDTO, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return IdentityDTO{}, err
@@ -145,7 +266,7 @@ func (c *ApiClient) GetIdentity() (IdentityDTO, error) {
 
 	u = u.JoinPath(identityEndpoint)
 
-	req, err := c.createRequest(http.MethodGet, u.String())
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
 	if err != nil {
 		return IdentityDTO{}, err
 	}
@@ -156,14 +277,14 @@ func (c *ApiClient) GetIdentity() (IdentityDTO, error) {
 	}
 
 	var identity IdentityDTO
-	if err := xml.Unmarshal(bytes, &identity); err != nil {
+	if err := unmarshalXML(bytes, &identity); err != nil {
 		return IdentityDTO{}, err
 	}
 
 	return identity, nil
 }
 
-func (c *ApiClient) GetResources() (ResourcesDTO, error) {
+func (c *ApiClient) GetResources(ctx context.Context) (ResourcesDTO, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return ResourcesDTO{}, err
@@ -176,7 +297,7 @@ func (c *ApiClient) GetResources() (ResourcesDTO, error) {
 	q.Set(queryKeyIncludeIPv6, plexTrue)
 	u.RawQuery = q.Encode()
 
-	req, err := c.createRequest(http.MethodGet, u.String())
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
 	if err != nil {
 		return ResourcesDTO{}, err
 	}
@@ -187,14 +308,59 @@ func (c *ApiClient) GetResources() (ResourcesDTO, error) {
 	}
 
 	var resources ResourcesDTO
-	if err := xml.Unmarshal(bytes, &resources); err != nil {
+	if err := unmarshalXML(bytes, &resources); err != nil {
 		return ResourcesDTO{}, err
 	}
 
 	return resources, nil
 }
 
-func (c *ApiClient) GetPreferences() (PreferencesDTO, error) {
+// GetResourcesV2 fetches the resources from the v2 (JSON) resources API, requiring the client to be created with
+// ClientsBaseURL and a client identifier
+func (c *ApiClient) GetResourcesV2(ctx context.Context) (ResourcesDTO, error) {
+	if c.clientIdentifier == "" {
+		return ResourcesDTO{}, fmt.Errorf("client identifier is required for v2 resources API")
+	}
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return ResourcesDTO{}, err
+	}
+
+	u = u.JoinPath(resourcesEndpoint)
+
+	q := u.Query()
+	q.Set(queryKeyIncludeHttps, plexTrue)
+	q.Set(queryKeyIncludeIPv6, plexTrue)
+	u.RawQuery = q.Encode()
+
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
+	if err != nil {
+		return ResourcesDTO{}, err
+	}
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+
+	bytes, err := c.do(req)
+	if err != nil {
+		return ResourcesDTO{}, err
+	}
+
+	var resources []resourceV2DTO
+	if err := unmarshalJSON(bytes, &resources); err != nil {
+		return ResourcesDTO{}, err
+	}
+
+	devices := make([]DeviceDTO, 0, len(resources))
+	for _, r := range resources {
+		devices = append(devices, r.toDeviceDTO())
+	}
+
+	return ResourcesDTO{
+		Devices: devices,
+	}, nil
+}
+
+func (c *ApiClient) GetPreferences(ctx context.Context) (PreferencesDTO, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return PreferencesDTO{}, err
@@ -202,7 +368,7 @@ func (c *ApiClient) GetPreferences() (PreferencesDTO, error) {
 
 	u = u.JoinPath(preferencesEndpoint)
 
-	req, err := c.createRequest(http.MethodGet, u.String())
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
 	if err != nil {
 		return PreferencesDTO{}, err
 	}
@@ -213,14 +379,14 @@ func (c *ApiClient) GetPreferences() (PreferencesDTO, error) {
 	}
 
 	var preferences PreferencesDTO
-	if err := xml.Unmarshal(bytes, &preferences); err != nil {
+	if err := unmarshalXML(bytes, &preferences); err != nil {
 		return PreferencesDTO{}, err
 	}
 
 	return preferences, nil
 }
 
-func (c *ApiClient) UpdateCustomConnections(customConnections string) error {
+func (c *ApiClient) UpdateCustomConnections(ctx context.Context, customConnections string) error {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return err
@@ -232,7 +398,7 @@ func (c *ApiClient) UpdateCustomConnections(customConnections string) error {
 	q.Set(queryKeyCustomConnections, customConnections)
 	u.RawQuery = q.Encode()
 
-	req, err := c.createRequest(http.MethodPut, u.String())
+	req, err := c.createRequest(ctx, http.MethodPut, u.String())
 	if err != nil {
 		return err
 	}
@@ -241,21 +407,66 @@ func (c *ApiClient) UpdateCustomConnections(customConnections string) error {
 	return err
 }
 
-func (c *ApiClient) createRequest(method string, u string) (*http.Request, error) {
-	req, err := http.NewRequest(method, u, nil)
+func (c *ApiClient) createRequest(ctx context.Context, method string, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set(headerKeyToken, c.token)
+	if c.token != "" {
+		req.Header.Set(headerKeyToken, c.token)
+	}
+	if c.clientIdentifier != "" {
+		req.Header.Set(headerKeyClientIdentifier, c.clientIdentifier)
+		req.Header.Set(headerKeyProduct, product)
+	}
+	if c.deviceName != "" {
+		req.Header.Set(headerKeyDeviceName, c.deviceName)
+	}
 
 	return req, nil
 }
 
-func (c *ApiClient) do(req *http.Request) ([]byte, error) {
+// do sends the request, retrying according to the retry policy. Any response status other than 200 OK is an error,
+// unless it is one of the given (additional) success statuses.
+func (c *ApiClient) do(req *http.Request, successStatuses ...int) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		bytes, retryAfter, err := c.doOnce(req, successStatuses)
+		if err == nil {
+			return bytes, nil
+		}
+
+		// Retrying is pointless once the request was cancelled or its deadline exceeded
+		if attempt >= c.retryPolicy.Attempts || retryAfter < 0 || req.Context().Err() != nil {
+			return nil, err
+		}
+
+		delay := c.retryPolicy.delay(attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+
+		log.Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("Plex API request failed, retrying")
+
+		if err = sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doOnce sends the request once, returning the response body on success. On failure, it also returns the delay
+// requested by the server via Retry-After (0 if none) or a negative delay if the request must not be retried.
+func (c *ApiClient) doOnce(req *http.Request, successStatuses []int) ([]byte, time.Duration, error) {
 	res, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		if !isRetryableError(req.Method, err) {
+			return nil, -1, newTransportError(err)
+		}
+		return nil, 0, newTransportError(err)
 	}
 
 	defer func() {
@@ -265,14 +476,31 @@ func (c *ApiClient) do(req *http.Request) ([]byte, error) {
 		}
 	}()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request to %s failed with status code %d (%s)", res.Request.URL.String(), res.StatusCode, res.Status)
+	if res.StatusCode != http.StatusOK && !slices.Contains(successStatuses, res.StatusCode) {
+		err = &StatusError{
+			URL:        redactURL(res.Request.URL.String()),
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+		}
+		if !isRetryableStatus(req.Method, res.StatusCode) {
+			return nil, -1, err
+		}
+
+		retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		if ok && retryAfter > maxRetryAfter {
+			return nil, -1, err
+		}
+		return nil, retryAfter, err
 	}
 
 	bytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		// The server did process the request, so only reading data can safely be retried
+		if req.Method != http.MethodGet {
+			return nil, -1, newTransportError(err)
+		}
+		return nil, 0, newTransportError(err)
 	}
 
-	return bytes, nil
+	return bytes, 0, nil
 }
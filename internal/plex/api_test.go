@@ -1,6 +1,7 @@
 package plex
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -53,7 +54,7 @@ func TestApiClient_GetIdentity(t *testing.T) {
 			client := NewApiClient(server.URL, token, timeout)
 
 			// WHEN
-			resources, err := client.GetIdentity()
+			resources, err := client.GetIdentity(context.Background())
 
 			// THEN
 			if tt.wantErrorContains != "" {
@@ -99,6 +100,7 @@ func TestApiClient_GetResources(t *testing.T) {
 						Name:             "MyPlexServer",
 						Product:          "Plex Media Server",
 						ClientIdentifier: "1142ed040a27acc36ea876e8362b28464c3d240d",
+						Owned:            "1",
 						Connections: []ConnectionDTO{
 							{
 								Protocol: "https",
@@ -118,6 +120,7 @@ func TestApiClient_GetResources(t *testing.T) {
 						Name:             "OtherPlexServer",
 						Product:          "Plex Media Server",
 						ClientIdentifier: "8cc5e1ff10756c3c4c7d0ada6189eabd06302cff",
+						Owned:            "0",
 						Connections: []ConnectionDTO{
 							{
 								Protocol: "https",
@@ -162,6 +165,7 @@ func TestApiClient_GetResources(t *testing.T) {
 						Name:             "MyPlexServer",
 						Product:          "Plex Media Server",
 						ClientIdentifier: "1142ed040a27acc36ea876e8362b28464c3d240d",
+						Owned:            "1",
 					},
 				},
 			},
@@ -190,7 +194,140 @@ func TestApiClient_GetResources(t *testing.T) {
 			client := NewApiClient(server.URL, token, timeout)
 
 			// WHEN
-			resources, err := client.GetResources()
+			resources, err := client.GetResources(context.Background())
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantResources, resources)
+			}
+		})
+	}
+}
+
+func TestApiClient_GetResourcesV2(t *testing.T) {
+	token := "some-token"
+	timeout := 5
+	clientIdentifier := "some-client-identifier"
+
+	tests := []struct {
+		name                  string
+		givenClientIdentifier string
+		givenStatusCode       int
+		givenData             []byte
+		wantResources         ResourcesDTO
+		wantErrorContains     string
+	}{
+		{
+			name:                  "successfully fetches resources",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       200,
+			givenData: []byte(`[
+				{
+					"name": "MyPlexServer",
+					"product": "Plex Media Server",
+					"productVersion": "some-version",
+					"clientIdentifier": "1142ed040a27acc36ea876e8362b28464c3d240d",
+					"provides": "server",
+					"owned": true,
+					"accessToken": "some-token",
+					"connections": [
+						{"protocol": "https", "address": "some.private.ip", "port": 32400, "uri": "https://some-private-ip.some-server-id.plex.direct:32400", "local": true, "relay": false, "IPv6": false},
+						{"protocol": "https", "address": "some.public.ip", "port": 32400, "uri": "https://some-public-ip.some-server-id.plex.direct:32400", "local": false, "relay": false, "IPv6": false}
+					]
+				},
+				{
+					"name": "SomeClient",
+					"product": "Plex for Android (TV)",
+					"clientIdentifier": "some-client-id",
+					"provides": "player",
+					"owned": true,
+					"connections": []
+				}
+			]`),
+			wantResources: ResourcesDTO{
+				Devices: []DeviceDTO{
+					{
+						Name:             "MyPlexServer",
+						Product:          "Plex Media Server",
+						ClientIdentifier: "1142ed040a27acc36ea876e8362b28464c3d240d",
+						Owned:            "1",
+						Connections: []ConnectionDTO{
+							{
+								Protocol: "https",
+								Address:  "some.private.ip",
+								URI:      "https://some-private-ip.some-server-id.plex.direct:32400",
+								Local:    "1",
+							},
+							{
+								Protocol: "https",
+								Address:  "some.public.ip",
+								URI:      "https://some-public-ip.some-server-id.plex.direct:32400",
+								Local:    "0",
+							},
+						},
+					},
+					{
+						Name:             "SomeClient",
+						Product:          "Plex for Android (TV)",
+						ClientIdentifier: "some-client-id",
+						Owned:            "1",
+						Connections:      []ConnectionDTO{},
+					},
+				},
+			},
+		},
+		{
+			name:                  "handles empty resources",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       200,
+			givenData:             []byte(`[]`),
+			wantResources: ResourcesDTO{
+				Devices: []DeviceDTO{},
+			},
+		},
+		{
+			name:                  "returns error for invalid JSON",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       200,
+			givenData:             []byte(`<MediaContainer size="0"></MediaContainer>`),
+			wantErrorContains:     "invalid character",
+		},
+		{
+			name:                  "returns error for non-200 response code",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       400,
+			wantErrorContains:     "failed with status code 400 (400 Bad Request)",
+		},
+		{
+			name:              "returns error without client identifier",
+			wantErrorContains: "client identifier is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, token, r.Header.Get(headerKeyToken))
+				assert.Equal(t, tt.givenClientIdentifier, r.Header.Get(headerKeyClientIdentifier))
+				assert.Equal(t, product, r.Header.Get(headerKeyProduct))
+				assert.Equal(t, contentTypeJSON, r.Header.Get(headerKeyAccept))
+				assert.Equal(t, resourcesEndpoint, r.URL.Path)
+				assert.Equal(t, plexTrue, r.URL.Query().Get(queryKeyIncludeHttps))
+				assert.Equal(t, plexTrue, r.URL.Query().Get(queryKeyIncludeIPv6))
+
+				w.WriteHeader(tt.givenStatusCode)
+				_, err := w.Write(tt.givenData)
+				require.NoError(t, err)
+			}))
+
+			client := NewApiClient(server.URL, token, timeout, WithClientIdentifier(tt.givenClientIdentifier))
+
+			// WHEN
+			resources, err := client.GetResourcesV2(context.Background())
 
 			// THEN
 			if tt.wantErrorContains != "" {
@@ -277,7 +414,7 @@ func TestApiClient_GetPreferences(t *testing.T) {
 			client := NewApiClient(server.URL, token, timeout)
 
 			// WHEN
-			resources, err := client.GetPreferences()
+			resources, err := client.GetPreferences(context.Background())
 
 			// THEN
 			if tt.wantErrorContains != "" {
@@ -325,13 +462,83 @@ func TestApiClient_UpdateCustomConnections(t *testing.T) {
 			client := NewApiClient(server.URL, token, timeout)
 
 			// WHEN
-			err := client.UpdateCustomConnections(customConnections)
+			err := client.UpdateCustomConnections(context.Background(), customConnections)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeviceDTO_GetPlexDirectHostname(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenConnections  []ConnectionDTO
+		wantHostname      string
+		wantErrorContains string
+	}{
+		{
+			name: "returns hostname of IPv4 connection",
+			givenConnections: []ConnectionDTO{
+				{Address: "192.168.1.2", URI: "https://192-168-1-2.some-server-id.plex.direct:32400"},
+			},
+			wantHostname: "some-server-id.plex.direct",
+		},
+		{
+			name: "returns hostname of IPv6 connection",
+			givenConnections: []ConnectionDTO{
+				{Address: "2001:db8::1", URI: "https://2001-db8--1.some-server-id.plex.direct:32400"},
+				{Address: "192.168.1.2", URI: "https://192-168-1-2.some-server-id.plex.direct:32400"},
+			},
+			wantHostname: "some-server-id.plex.direct",
+		},
+		{
+			name: "ignores connections without plex.direct hostname",
+			givenConnections: []ConnectionDTO{
+				{Address: "192.168.1.2", URI: "http://192.168.1.2:32400"},
+				{Address: "plex.example.com", URI: "https://plex.example.com:443"},
+				{Address: "2001:db8::1", URI: "https://2001-db8--1.some-server-id.plex.direct:32400"},
+			},
+			wantHostname: "some-server-id.plex.direct",
+		},
+		{
+			name: "returns error if connections use different hostnames",
+			givenConnections: []ConnectionDTO{
+				{Address: "2001:db8::1", URI: "https://2001-db8--1.some-server-id.plex.direct:32400"},
+				{Address: "192.168.1.2", URI: "https://192-168-1-2.other-server-id.plex.direct:32400"},
+			},
+			wantErrorContains: "connections of device MyPlexServer use different .plex.direct hostnames",
+		},
+		{
+			name: "returns error if no connection uses plex.direct hostname",
+			givenConnections: []ConnectionDTO{
+				{Address: "192.168.1.2", URI: "http://192.168.1.2:32400"},
+			},
+			wantErrorContains: "no .plex.direct hostname found for device: MyPlexServer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			device := DeviceDTO{
+				Name:        "MyPlexServer",
+				Connections: tt.givenConnections,
+			}
+
+			// WHEN
+			hostname, err := device.GetPlexDirectHostname()
 
 			// THEN
 			if tt.wantErrorContains != "" {
 				assert.ErrorContains(t, err, tt.wantErrorContains)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tt.wantHostname, hostname)
 			}
 		})
 	}
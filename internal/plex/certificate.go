@@ -0,0 +1,129 @@
+package plex
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultServerPort = "32400"
+
+	plexDirectVerificationLabel = "verify"
+)
+
+// GetCertificatePlexDirectHostname connects to the Plex server at the given address (in format http[s]://host:port)
+// via TLS and returns the `[server-hash].plex.direct` hostname from the server's wildcard certificate
+// (`*.[server-hash].plex.direct`). Plex serves HTTPS on the same port as HTTP, so the scheme does not matter.
+// The certificate is verified for the plex.direct hostname according to the given TLS config (using its root CAs,
+// pinned certificate or skipping verification if configured to do so).
+func GetCertificatePlexDirectHostname(ctx context.Context, serverAddr string, timeout int, config *tls.Config) (string, error) {
+	u, err := url.Parse(serverAddr)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("no host found in server address: %s", serverAddr)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultServerPort
+	}
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	// The certificate is issued for the plex.direct hostname rather than the address we connect to, so the default
+	// verification would always fail. Instead, the certificate is verified for the plex.direct hostname it contains
+	// once connected. Any pinned certificate is still verified via VerifyConnection.
+	dialConfig := config.Clone()
+	dialConfig.InsecureSkipVerify = true //nolint:gosec
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{
+			Timeout: time.Second * time.Duration(timeout),
+		},
+		Config: dialConfig,
+	}
+	netConn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return "", err
+	}
+	conn := netConn.(*tls.Conn)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return "", fmt.Errorf("no certificate presented by server: %s", serverAddr)
+	}
+
+	hostname, err := getPlexDirectHostnameFromDNSNames(certificates[0].DNSNames)
+	if err != nil {
+		return "", err
+	}
+
+	// Certificates are either pinned (and thus already verified) or explicitly not verified if skipping verification
+	if !config.InsecureSkipVerify {
+		if err = verifyPlexDirectCertificate(certificates, hostname, config.RootCAs); err != nil {
+			return "", err
+		}
+	}
+
+	return hostname, nil
+}
+
+// verifyPlexDirectCertificate verifies the certificate chain against the given roots (the system's if nil)
+// for a name covered by the wildcard plex.direct hostname
+func verifyPlexDirectCertificate(certificates []*x509.Certificate, hostname string, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range certificates[1:] {
+		intermediates.AddCert(c)
+	}
+
+	// The wildcard certificate is valid for any single label below the plex.direct hostname
+	_, err := certificates[0].Verify(x509.VerifyOptions{
+		DNSName:       plexDirectVerificationLabel + "." + hostname,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify certificate for %s: %w", hostname, err)
+	}
+
+	return nil
+}
+
+func getPlexDirectHostnameFromDNSNames(names []string) (string, error) {
+	var hostname string
+	for _, name := range names {
+		wildcard, ok := strings.CutPrefix(strings.ToLower(name), "*.")
+		if !ok {
+			continue
+		}
+
+		labels := strings.Split(wildcard, ".")
+		if len(labels) != 3 || labels[0] == "" || labels[1] != "plex" || labels[2] != "direct" {
+			continue
+		}
+
+		if hostname != "" && wildcard != hostname {
+			return "", fmt.Errorf("certificate contains different .plex.direct hostnames: %s, %s", hostname, wildcard)
+		}
+		hostname = wildcard
+	}
+
+	if hostname == "" {
+		return "", fmt.Errorf("no .plex.direct hostname found in certificate")
+	}
+
+	return hostname, nil
+}
@@ -0,0 +1,151 @@
+package plex
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCertificatePlexDirectHostname(t *testing.T) {
+	timeout := 5
+	trusted := func(cert tls.Certificate) *tls.Config {
+		pool := x509.NewCertPool()
+		pool.AddCert(cert.Leaf)
+		return &tls.Config{RootCAs: pool}
+	}
+
+	tests := []struct {
+		name              string
+		givenDNSNames     []string
+		givenTLSConfig    func(cert tls.Certificate) *tls.Config
+		wantHostname      string
+		wantErrorContains string
+	}{
+		{
+			name:           "returns hostname from wildcard certificate",
+			givenDNSNames:  []string{"*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: trusted,
+			wantHostname:   "1142ed040a27acc36ea876e8362b2846.plex.direct",
+		},
+		{
+			name:           "ignores non-plex.direct and non-wildcard names",
+			givenDNSNames:  []string{"plex.example.com", "1-2-3-4.1142ed040a27acc36ea876e8362b2846.plex.direct", "*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: trusted,
+			wantHostname:   "1142ed040a27acc36ea876e8362b2846.plex.direct",
+		},
+		{
+			name:          "returns hostname from pinned certificate",
+			givenDNSNames: []string{"*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: func(cert tls.Certificate) *tls.Config {
+				fingerprint := sha256.Sum256(cert.Leaf.Raw)
+				config, err := NewTLSConfig(TLSOptions{CertSHA256: hex.EncodeToString(fingerprint[:])})
+				require.NoError(t, err)
+				return config
+			},
+			wantHostname: "1142ed040a27acc36ea876e8362b2846.plex.direct",
+		},
+		{
+			name:          "returns hostname from unverified certificate if skipping verification",
+			givenDNSNames: []string{"*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: func(_ tls.Certificate) *tls.Config {
+				return &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+			},
+			wantHostname: "1142ed040a27acc36ea876e8362b2846.plex.direct",
+		},
+		{
+			name:          "returns error for untrusted certificate",
+			givenDNSNames: []string{"*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: func(_ tls.Certificate) *tls.Config {
+				return nil
+			},
+			wantErrorContains: "failed to verify certificate for 1142ed040a27acc36ea876e8362b2846.plex.direct",
+		},
+		{
+			name:          "returns error for certificate not matching pinned fingerprint",
+			givenDNSNames: []string{"*.1142ed040a27acc36ea876e8362b2846.plex.direct"},
+			givenTLSConfig: func(_ tls.Certificate) *tls.Config {
+				config, err := NewTLSConfig(TLSOptions{CertSHA256: strings.Repeat("00", sha256.Size)})
+				require.NoError(t, err)
+				return config
+			},
+			wantErrorContains: "does not match pinned fingerprint",
+		},
+		{
+			name:              "returns error for different plex.direct hostnames",
+			givenDNSNames:     []string{"*.some-server-id.plex.direct", "*.other-server-id.plex.direct"},
+			givenTLSConfig:    trusted,
+			wantErrorContains: "certificate contains different .plex.direct hostnames",
+		},
+		{
+			name:              "returns error if certificate does not contain plex.direct hostname",
+			givenDNSNames:     []string{"plex.example.com"},
+			givenTLSConfig:    trusted,
+			wantErrorContains: "no .plex.direct hostname found in certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			cert := newTestCertificate(t, tt.givenDNSNames)
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{
+				Certificates: []tls.Certificate{cert},
+			}
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			// WHEN
+			hostname, err := GetCertificatePlexDirectHostname(context.Background(), server.URL, timeout, tt.givenTLSConfig(cert))
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantHostname, hostname)
+			}
+		})
+	}
+}
+
+func newTestCertificate(t *testing.T, dnsNames []string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -121,6 +122,10 @@ func (p *Preferences) GetCustomConnections() []string {
 	return strings.Split(p.getValue(preferenceKeyCustomConnections), ",")
 }
 
+func (p *Preferences) SetCustomConnections(customConnections string) {
+	(*p)[preferenceKeyCustomConnections] = customConnections
+}
+
 func ReadConfigFile(path string) (Config, error) {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
@@ -128,7 +133,7 @@ func ReadConfigFile(path string) (Config, error) {
 	}
 
 	var data Preferences
-	if err := xml.Unmarshal(bytes, &data); err != nil {
+	if err := unmarshalXML(bytes, &data); err != nil {
 		return Config{}, err
 	}
 
@@ -147,5 +152,86 @@ func WriteConfigFile(config Config) error {
 	// Marshal-ing does not add a prolog/header, so add it now (followed by a line-break)
 	bytes = append([]byte(fmt.Sprintf("%s\n", xmlHeader)), bytes...)
 
-	return os.WriteFile(config.Path, bytes, 0600)
+	return writeFileAtomic(config.Path, bytes)
+}
+
+// errOwnerNotKept indicates that a replacement file could not be given the owner of the file it replaces
+var errOwnerNotKept = errors.New("cannot keep file owner")
+
+// writeFileAtomic writes to a temporary file in the same directory first and then replaces the file with it, so an
+// interrupted write (e.g. due to a crash or a full disk) cannot leave a truncated file behind. The file's mode and
+// owner are kept, so the Plex server can still write to it, even if written by a different user (e.g. root). If the
+// owner cannot be kept (e.g. when writing a group-writable file owned by someone else), the file is written in place
+// instead.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if err = writeAndSync(f, data, info); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		if errors.Is(err, errOwnerNotKept) {
+			return writeFileInPlace(path, data)
+		}
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err = os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+func writeAndSync(f *os.File, data []byte, info os.FileInfo) error {
+	mode := os.FileMode(0600)
+	if info != nil {
+		mode = info.Mode().Perm()
+		if err := chownLike(f, info); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// writeFileInPlace truncates and writes the existing file, which keeps its owner and mode
+func writeFileInPlace(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
 }
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package plex
+
+import (
+	"os"
+)
+
+func chownLike(_ *os.File, _ os.FileInfo) error {
+	return nil
+}
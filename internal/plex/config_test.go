@@ -2,6 +2,7 @@ package plex
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -224,3 +225,34 @@ func TestWriteConfigFile(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteConfigFile_ReplacesFile(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Preferences.xml")
+	err := os.WriteFile(path, []byte("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<Preferences FriendlyName=\"MyPlexServer\"/>"), 0640)
+	require.NoError(t, err)
+	// Make sure the mode is not affected by the umask
+	err = os.Chmod(path, 0640)
+	require.NoError(t, err)
+
+	config := Config{
+		Path:        path,
+		Preferences: Preferences{"FriendlyName": "MyOtherPlexServer"},
+	}
+
+	// WHEN
+	err = WriteConfigFile(config)
+
+	// THEN
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "FriendlyName=\"MyOtherPlexServer\"")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should not be left behind")
+}
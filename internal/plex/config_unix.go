@@ -0,0 +1,36 @@
+//go:build unix
+
+package plex
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// chownLike gives the file the same owner as the file described by info. Changing the owner requires privileges,
+// so errOwnerNotKept is returned if the owners differ and the current user lacks them.
+func chownLike(f *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	current, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if currentStat, ok := current.Sys().(*syscall.Stat_t); ok && currentStat.Uid == stat.Uid && currentStat.Gid == stat.Gid {
+		return nil
+	}
+
+	if err = f.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w: %w", errOwnerNotKept, err)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build unix
+
+package plex
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Set when the test binary is run again as an unprivileged user in order to write the given config file
+	envUnprivilegedConfigPath = "PLEX_TEST_UNPRIVILEGED_CONFIG_PATH"
+
+	unprivilegedID = 65534 // nobody/nogroup
+)
+
+func TestWriteConfigFile_KeepsOwnerWithoutChownPrivileges(t *testing.T) {
+	if path := os.Getenv(envUnprivilegedConfigPath); path != "" {
+		// Running as the unprivileged user
+		err := WriteConfigFile(Config{
+			Path:        path,
+			Preferences: Preferences{"FriendlyName": "MyOtherPlexServer"},
+		})
+		require.NoError(t, err)
+		return
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("requires root in order to create a file owned by another user and run as an unprivileged user")
+	}
+
+	// GIVEN
+	dir := t.TempDir()
+	// Allow the unprivileged user to access the directory (and create temporary files in it)
+	require.NoError(t, os.Chmod(filepath.Dir(dir), 0755))
+	require.NoError(t, os.Chmod(dir, 0777))
+
+	// Config owned by root, writable by the unprivileged user's group
+	path := filepath.Join(dir, "Preferences.xml")
+	err := os.WriteFile(path, []byte("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<Preferences FriendlyName=\"MyPlexServer\"/>"), 0660)
+	require.NoError(t, err)
+	require.NoError(t, os.Chmod(path, 0660))
+	require.NoError(t, os.Chown(path, 0, unprivilegedID))
+
+	// The test binary may not be accessible for the unprivileged user where it is
+	executable := filepath.Join(t.TempDir(), "plex.test")
+	require.NoError(t, os.Chmod(filepath.Dir(executable), 0755))
+	copyTestBinary(t, executable)
+
+	cmd := exec.Command(executable, "-test.run=^TestWriteConfigFile_KeepsOwnerWithoutChownPrivileges$")
+	cmd.Env = append(os.Environ(), envUnprivilegedConfigPath+"="+path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: unprivilegedID, Gid: unprivilegedID},
+	}
+
+	// WHEN
+	out, err := cmd.CombinedOutput()
+
+	// THEN
+	require.NoError(t, err, string(out))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "FriendlyName=\"MyOtherPlexServer\"")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	stat := info.Sys().(*syscall.Stat_t)
+	assert.Equal(t, uint32(0), stat.Uid)
+	assert.Equal(t, uint32(unprivilegedID), stat.Gid)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should not be left behind")
+}
+
+// copyTestBinary copies the running test binary to the given path
+func copyTestBinary(t *testing.T, dst string) {
+	t.Helper()
+
+	src, err := os.Executable()
+	require.NoError(t, err)
+
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	require.NoError(t, err)
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+}
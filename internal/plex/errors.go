@@ -0,0 +1,120 @@
+package plex
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	redactedValue = "REDACTED"
+)
+
+var (
+	// ErrUnauthorized indicates that the token was rejected (or lacks the required permissions)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound indicates that the requested resource does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrSettingMissing indicates that the server's preferences do not contain a required setting
+	ErrSettingMissing = errors.New("no such setting")
+	// ErrDeviceNotFound indicates that the plex.tv resources do not contain the requested device
+	ErrDeviceNotFound = errors.New("no such device")
+	// ErrTransport indicates that a request failed without receiving a response
+	ErrTransport = errors.New("transport failure")
+	// ErrParse indicates that a response or file could not be parsed
+	ErrParse = errors.New("parse failure")
+)
+
+// StatusError is returned if a request failed with a non-success status code.
+// It matches ErrUnauthorized/ErrNotFound via errors.Is, depending on the status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request to %s failed with status code %d (%s)", e.URL, e.StatusCode, e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	default:
+		return false
+	}
+}
+
+// TransportError is returned if a request failed without receiving a response, matching ErrTransport via errors.Is
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() []error {
+	return []error{e.Err, ErrTransport}
+}
+
+// ParseError is returned if a response or file could not be parsed, matching ErrParse via errors.Is
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() []error {
+	return []error{e.Err, ErrParse}
+}
+
+// redactURL replaces the token in the URL's query (if any), so the URL can safely be included in errors
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	q := parsed.Query()
+	if !q.Has(headerKeyToken) {
+		return u
+	}
+
+	q.Set(headerKeyToken, redactedValue)
+	parsed.RawQuery = q.Encode()
+
+	return parsed.String()
+}
+
+// newTransportError wraps the error of a failed request, redacting the token from the request URL
+func newTransportError(err error) *TransportError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(urlErr.URL)
+	}
+
+	return &TransportError{Err: err}
+}
+
+func unmarshalXML(data []byte, v any) error {
+	if err := xml.Unmarshal(data, v); err != nil {
+		return &ParseError{Err: err}
+	}
+	return nil
+}
+
+func unmarshalJSON(data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return &ParseError{Err: err}
+	}
+	return nil
+}
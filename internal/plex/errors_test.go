@@ -0,0 +1,146 @@
+package plex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiClient_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		givenStatusCode int
+		givenData       []byte
+		givenClosed     bool
+		wantError       error
+	}{
+		{
+			name:            "returns unauthorized error for 401 response code",
+			givenStatusCode: 401,
+			wantError:       ErrUnauthorized,
+		},
+		{
+			name:            "returns unauthorized error for 403 response code",
+			givenStatusCode: 403,
+			wantError:       ErrUnauthorized,
+		},
+		{
+			name:            "returns not found error for 404 response code",
+			givenStatusCode: 404,
+			wantError:       ErrNotFound,
+		},
+		{
+			name:            "returns parse error for invalid response",
+			givenStatusCode: 200,
+			givenData:       []byte(`<MediaContainer`),
+			wantError:       ErrParse,
+		},
+		{
+			name:        "returns transport error if server is unreachable",
+			givenClosed: true,
+			wantError:   ErrTransport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.givenStatusCode)
+				_, _ = w.Write(tt.givenData)
+			}))
+			if tt.givenClosed {
+				server.Close()
+			} else {
+				t.Cleanup(server.Close)
+			}
+
+			client := NewApiClient(server.URL, "some-token", 5)
+
+			// WHEN
+			_, err := client.GetIdentity(context.Background())
+
+			// THEN
+			assert.ErrorIs(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestApiClient_ErrorsForOtherSuccessStatus(t *testing.T) {
+	// GIVEN
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewApiClient(server.URL, "some-token", 5)
+
+	// WHEN
+	_, err := client.GetPreferences(context.Background())
+
+	// THEN
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNoContent, statusErr.StatusCode)
+}
+
+func TestApiClient_ErrorsRedactToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		givenClosed bool
+	}{
+		{
+			name: "redacts token from status error",
+		},
+		{
+			name:        "redacts token from transport error",
+			givenClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			if tt.givenClosed {
+				server.Close()
+			} else {
+				t.Cleanup(server.Close)
+			}
+
+			client := NewApiClient(server.URL+"?X-Plex-Token=some-secret-token", "some-token", 5)
+
+			// WHEN
+			_, err := client.GetIdentity(context.Background())
+
+			// THEN
+			require.Error(t, err)
+			assert.NotContains(t, err.Error(), "some-secret-token")
+			assert.Contains(t, err.Error(), "X-Plex-Token=REDACTED")
+		})
+	}
+}
+
+func TestResourcesDTO_GetDeviceByIdentifier_NotFound(t *testing.T) {
+	// WHEN
+	_, err := ResourcesDTO{}.GetDeviceByIdentifier("some-client-identifier")
+
+	// THEN
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	assert.EqualError(t, err, "no such device: some-client-identifier")
+}
+
+func TestPreferencesDTO_GetSettingByID_Missing(t *testing.T) {
+	// WHEN
+	_, err := PreferencesDTO{}.GetSettingByID(SettingIDCustomConnections)
+
+	// THEN
+	assert.ErrorIs(t, err, ErrSettingMissing)
+	assert.EqualError(t, err, "no such setting: customConnections")
+}
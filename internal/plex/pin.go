@@ -0,0 +1,85 @@
+package plex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// LinkURL is where users enter a PIN's code in order to authorize it
+	LinkURL = "https://plex.tv/link"
+
+	pinsEndpoint = "/pins"
+)
+
+// PinDTO is a PIN used to obtain a token by having the user authorize the PIN's code
+type PinDTO struct {
+	ID        int    `json:"id"`
+	Code      string `json:"code"`
+	AuthToken string `json:"authToken"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+// IsAuthorized returns whether the user authorized the PIN, meaning it contains a token
+func (p PinDTO) IsAuthorized() bool {
+	return p.AuthToken != ""
+}
+
+// CreatePin creates a (short, non-strong) PIN, whose code the user can enter at LinkURL. Requires the client to be
+// created with AuthBaseURL and a client identifier.
+func (c *ApiClient) CreatePin(ctx context.Context) (PinDTO, error) {
+	if c.clientIdentifier == "" {
+		return PinDTO{}, fmt.Errorf("client identifier is required for creating a PIN")
+	}
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return PinDTO{}, err
+	}
+
+	u = u.JoinPath(pinsEndpoint)
+
+	req, err := c.createRequest(ctx, http.MethodPost, u.String())
+	if err != nil {
+		return PinDTO{}, err
+	}
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+
+	// plex.tv responds with 201 Created for new PINs
+	return c.doPin(req, http.StatusCreated)
+}
+
+// GetPin fetches the PIN with the given id in order to check whether it has been authorized
+func (c *ApiClient) GetPin(ctx context.Context, id int) (PinDTO, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return PinDTO{}, err
+	}
+
+	u = u.JoinPath(pinsEndpoint, strconv.Itoa(id))
+
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
+	if err != nil {
+		return PinDTO{}, err
+	}
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+
+	return c.doPin(req)
+}
+
+func (c *ApiClient) doPin(req *http.Request, successStatuses ...int) (PinDTO, error) {
+	bytes, err := c.do(req, successStatuses...)
+	if err != nil {
+		return PinDTO{}, err
+	}
+
+	var pin PinDTO
+	if err := unmarshalJSON(bytes, &pin); err != nil {
+		return PinDTO{}, err
+	}
+
+	return pin, nil
+}
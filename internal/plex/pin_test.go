@@ -0,0 +1,151 @@
+package plex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiClient_CreatePin(t *testing.T) {
+	timeout := 5
+	clientIdentifier := "some-client-identifier"
+
+	tests := []struct {
+		name                  string
+		givenClientIdentifier string
+		givenStatusCode       int
+		givenData             []byte
+		wantPin               PinDTO
+		wantErrorContains     string
+	}{
+		{
+			name:                  "successfully creates pin",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       201,
+			givenData:             []byte(`{"id":1234567890,"code":"ABCD","product":"update-plex-ipv6-access-url","trusted":false,"qr":"https://plex.tv/api/v2/pins/qr/ABCD","clientIdentifier":"some-client-identifier","expiresIn":900,"createdAt":"2024-01-01T12:00:00Z","expiresAt":"2024-01-01T12:15:00Z","authToken":null,"newRegistration":null}`),
+			wantPin: PinDTO{
+				ID:        1234567890,
+				Code:      "ABCD",
+				ExpiresIn: 900,
+			},
+		},
+		{
+			name:                  "returns error for non-2xx response code",
+			givenClientIdentifier: clientIdentifier,
+			givenStatusCode:       400,
+			wantErrorContains:     "failed with status code 400 (400 Bad Request)",
+		},
+		{
+			name:              "returns error without client identifier",
+			wantErrorContains: "client identifier is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, pinsEndpoint, r.URL.Path)
+				assert.Empty(t, r.Header.Get(headerKeyToken))
+				assert.Equal(t, tt.givenClientIdentifier, r.Header.Get(headerKeyClientIdentifier))
+				assert.Equal(t, product, r.Header.Get(headerKeyProduct))
+				assert.Equal(t, contentTypeJSON, r.Header.Get(headerKeyAccept))
+
+				w.WriteHeader(tt.givenStatusCode)
+				_, err := w.Write(tt.givenData)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			client := NewApiClient(server.URL, "", timeout, WithClientIdentifier(tt.givenClientIdentifier))
+
+			// WHEN
+			pin, err := client.CreatePin(context.Background())
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantPin, pin)
+			}
+		})
+	}
+}
+
+func TestApiClient_GetPin(t *testing.T) {
+	timeout := 5
+	clientIdentifier := "some-client-identifier"
+
+	tests := []struct {
+		name              string
+		givenStatusCode   int
+		givenData         []byte
+		wantPin           PinDTO
+		wantAuthorized    bool
+		wantErrorContains string
+	}{
+		{
+			name:            "successfully fetches unauthorized pin",
+			givenStatusCode: 200,
+			givenData:       []byte(`{"id":1234567890,"code":"ABCD","expiresIn":600,"authToken":null}`),
+			wantPin: PinDTO{
+				ID:        1234567890,
+				Code:      "ABCD",
+				ExpiresIn: 600,
+			},
+		},
+		{
+			name:            "successfully fetches authorized pin",
+			givenStatusCode: 200,
+			givenData:       []byte(`{"id":1234567890,"code":"ABCD","expiresIn":600,"authToken":"some-token"}`),
+			wantPin: PinDTO{
+				ID:        1234567890,
+				Code:      "ABCD",
+				AuthToken: "some-token",
+				ExpiresIn: 600,
+			},
+			wantAuthorized: true,
+		},
+		{
+			name:              "returns error for expired pin",
+			givenStatusCode:   404,
+			wantErrorContains: "failed with status code 404 (404 Not Found)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, pinsEndpoint+"/1234567890", r.URL.Path)
+				assert.Equal(t, clientIdentifier, r.Header.Get(headerKeyClientIdentifier))
+
+				w.WriteHeader(tt.givenStatusCode)
+				_, err := w.Write(tt.givenData)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			client := NewApiClient(server.URL, "", timeout, WithClientIdentifier(clientIdentifier))
+
+			// WHEN
+			pin, err := client.GetPin(context.Background(), 1234567890)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantPin, pin)
+				assert.Equal(t, tt.wantAuthorized, pin.IsAuthorized())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package plex
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Maximum delay requested via Retry-After we are willing to wait for, longer delays are not retried
+	maxRetryAfter = 2 * time.Minute
+	// Maximum delay between retries (unless the base delay is longer), no matter how many attempts were made
+	maxRetryDelay = time.Minute
+)
+
+type RetryPolicy struct {
+	// Maximum number of attempts (including the first one), values below 1 are treated as 1
+	Attempts int
+	// Delay before the first retry, doubling with each further retry
+	BaseDelay time.Duration
+	// Fraction by which delays are randomly increased/decreased (0 to 1)
+	Jitter float64
+}
+
+// NoRetryPolicy does not retry any requests
+var NoRetryPolicy = RetryPolicy{Attempts: 1}
+
+// delay returns how long to wait before the given retry (starting at 1)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	// Double the delay step by step, since shifting by the number of retries would overflow for many attempts
+	limit := max(p.BaseDelay, maxRetryDelay)
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < limit; i++ {
+		delay *= 2
+	}
+	delay = min(delay, limit)
+
+	if p.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + p.Jitter*(rand.Float64()*2-1))) //nolint:gosec
+	}
+	return delay
+}
+
+// isRetryableError returns whether a request which failed with the given (transport) error can be retried
+func isRetryableError(method string, err error) bool {
+	if method == http.MethodGet {
+		return true
+	}
+
+	// Other requests are only safe to retry if they never reached the server
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isRetryableStatus returns whether a request which failed with the given status code can be retried
+func isRetryableStatus(method string, statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// Server explicitly did not process the request
+		return true
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the given duration, returning early with the context's error if it is done before
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,326 @@
+package plex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiClient_Retry(t *testing.T) {
+	token := "some-token"
+	timeout := 5
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name              string
+		givenMethod       string
+		givenStatusCodes  []int
+		givenRetryAfter   string
+		wantRequests      int
+		wantErrorContains string
+	}{
+		{
+			name:             "retries GET request on server error",
+			givenMethod:      http.MethodGet,
+			givenStatusCodes: []int{500, 502, 200},
+			wantRequests:     3,
+		},
+		{
+			name:             "retries GET request on request timeout",
+			givenMethod:      http.MethodGet,
+			givenStatusCodes: []int{408, 200},
+			wantRequests:     2,
+		},
+		{
+			name:              "gives up after maximum number of attempts",
+			givenMethod:       http.MethodGet,
+			givenStatusCodes:  []int{503, 503, 503, 200},
+			wantRequests:      3,
+			wantErrorContains: "failed with status code 503",
+		},
+		{
+			name:              "does not retry GET request on client error",
+			givenMethod:       http.MethodGet,
+			givenStatusCodes:  []int{401, 200},
+			wantRequests:      1,
+			wantErrorContains: "failed with status code 401",
+		},
+		{
+			name:             "honors Retry-After",
+			givenMethod:      http.MethodGet,
+			givenStatusCodes: []int{429, 200},
+			givenRetryAfter:  "0",
+			wantRequests:     2,
+		},
+		{
+			name:              "does not retry if Retry-After exceeds maximum",
+			givenMethod:       http.MethodGet,
+			givenStatusCodes:  []int{429, 200},
+			givenRetryAfter:   "3600",
+			wantRequests:      1,
+			wantErrorContains: "failed with status code 429",
+		},
+		{
+			name:             "retries PUT request if server is unavailable",
+			givenMethod:      http.MethodPut,
+			givenStatusCodes: []int{503, 200},
+			wantRequests:     2,
+		},
+		{
+			name:             "retries PUT request on too many requests",
+			givenMethod:      http.MethodPut,
+			givenStatusCodes: []int{429, 200},
+			wantRequests:     2,
+		},
+		{
+			name:              "does not retry PUT request on server error",
+			givenMethod:       http.MethodPut,
+			givenStatusCodes:  []int{500, 200},
+			wantRequests:      1,
+			wantErrorContains: "failed with status code 500",
+		},
+		{
+			name:              "does not retry PUT request on gateway timeout",
+			givenMethod:       http.MethodPut,
+			givenStatusCodes:  []int{504, 200},
+			wantRequests:      1,
+			wantErrorContains: "failed with status code 504",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.givenMethod, r.Method)
+
+				if tt.givenRetryAfter != "" {
+					w.Header().Set("Retry-After", tt.givenRetryAfter)
+				}
+				w.WriteHeader(tt.givenStatusCodes[requests])
+				requests++
+			}))
+			defer server.Close()
+
+			client := NewApiClient(server.URL, token, timeout, WithRetryPolicy(policy))
+			req, err := http.NewRequest(tt.givenMethod, server.URL, nil)
+			require.NoError(t, err)
+
+			// WHEN
+			_, err = client.do(req)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+		})
+	}
+}
+
+// countingTransport counts the requests sent via the underlying transport
+type countingTransport struct {
+	transport http.RoundTripper
+	requests  int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return t.transport.RoundTrip(req)
+}
+
+func TestApiClient_RetryTransportError(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name         string
+		givenMethod  string
+		givenReached bool
+		wantRequests int
+	}{
+		{
+			name:         "retries GET request which did not reach server",
+			givenMethod:  http.MethodGet,
+			wantRequests: policy.Attempts,
+		},
+		{
+			name:         "retries GET request which reached server",
+			givenMethod:  http.MethodGet,
+			givenReached: true,
+			wantRequests: policy.Attempts,
+		},
+		{
+			name:         "retries PUT request which did not reach server",
+			givenMethod:  http.MethodPut,
+			wantRequests: policy.Attempts,
+		},
+		{
+			name:         "does not retry PUT request which reached server",
+			givenMethod:  http.MethodPut,
+			givenReached: true,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Drop the connection without responding
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				_ = conn.Close()
+			}))
+			if tt.givenReached {
+				t.Cleanup(server.Close)
+			} else {
+				// Close server right away, so connections are refused
+				server.Close()
+			}
+
+			client := NewApiClient(server.URL, "some-token", 5, WithRetryPolicy(policy))
+			transport := &countingTransport{transport: http.DefaultTransport.(*http.Transport).Clone()}
+			client.client.Transport = transport
+			req, err := http.NewRequest(tt.givenMethod, server.URL, nil)
+			require.NoError(t, err)
+
+			// WHEN
+			_, err = client.do(req)
+
+			// THEN
+			assert.ErrorIs(t, err, ErrTransport)
+			assert.Equal(t, tt.wantRequests, transport.requests)
+		})
+	}
+}
+
+func TestApiClient_RetryCancelled(t *testing.T) {
+	// GIVEN
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewApiClient(server.URL, "some-token", 5, WithRetryPolicy(RetryPolicy{Attempts: 3, BaseDelay: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	// WHEN
+	_, err = client.do(req)
+
+	// THEN
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	tests := []struct {
+		name           string
+		givenBaseDelay time.Duration
+		givenRetry     int
+		wantDelay      time.Duration
+	}{
+		{
+			name:           "uses base delay for first retry",
+			givenBaseDelay: time.Second,
+			givenRetry:     1,
+			wantDelay:      time.Second,
+		},
+		{
+			name:           "doubles delay for each further retry",
+			givenBaseDelay: time.Second,
+			givenRetry:     3,
+			wantDelay:      4 * time.Second,
+		},
+		{
+			name:           "limits delay to maximum",
+			givenBaseDelay: time.Second,
+			givenRetry:     100,
+			wantDelay:      maxRetryDelay,
+		},
+		{
+			name:           "does not shorten base delay longer than maximum",
+			givenBaseDelay: 2 * maxRetryDelay,
+			givenRetry:     3,
+			wantDelay:      2 * maxRetryDelay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			policy := RetryPolicy{BaseDelay: tt.givenBaseDelay}
+
+			// WHEN
+			delay := policy.delay(tt.givenRetry)
+
+			// THEN
+			assert.Equal(t, tt.wantDelay, delay)
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		givenValue string
+		wantDelay  time.Duration
+		wantOK     bool
+	}{
+		{
+			name:       "parses seconds",
+			givenValue: "30",
+			wantDelay:  30 * time.Second,
+			wantOK:     true,
+		},
+		{
+			name:       "parses HTTP date",
+			givenValue: "Mon, 01 Jan 2024 12:01:00 GMT",
+			wantDelay:  time.Minute,
+			wantOK:     true,
+		},
+		{
+			name:       "treats HTTP date in the past as no delay",
+			givenValue: "Mon, 01 Jan 2024 11:00:00 GMT",
+			wantDelay:  0,
+			wantOK:     true,
+		},
+		{
+			name:       "ignores empty value",
+			givenValue: "",
+		},
+		{
+			name:       "ignores negative seconds",
+			givenValue: "-1",
+		},
+		{
+			name:       "ignores invalid value",
+			givenValue: "soon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			delay, ok := parseRetryAfter(tt.givenValue, now)
+
+			// THEN
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantDelay, delay)
+		})
+	}
+}
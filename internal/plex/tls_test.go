@@ -0,0 +1,175 @@
+package plex
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiClient_TLS(t *testing.T) {
+	certificate := newTestCertificate(t, []string{"*.some-server-id.plex.direct"})
+	fingerprint := sha256.Sum256(certificate.Certificate[0])
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600))
+
+	tests := []struct {
+		name              string
+		givenOptions      TLSOptions
+		wantErrorContains string
+	}{
+		{
+			name: "verifies certificate against CA file and server name",
+			givenOptions: TLSOptions{
+				CAFile:     caFile,
+				ServerName: "1-2-3-4.some-server-id.plex.direct",
+			},
+		},
+		{
+			name: "returns error if certificate does not match server address",
+			givenOptions: TLSOptions{
+				CAFile: caFile,
+			},
+			wantErrorContains: "cannot validate certificate for 127.0.0.1",
+		},
+		{
+			name: "returns error if certificate is not signed by trusted CA",
+			givenOptions: TLSOptions{
+				ServerName: "1-2-3-4.some-server-id.plex.direct",
+			},
+			wantErrorContains: "certificate signed by unknown authority",
+		},
+		{
+			name: "accepts certificate matching pinned fingerprint",
+			givenOptions: TLSOptions{
+				CertSHA256: hex.EncodeToString(fingerprint[:]),
+			},
+		},
+		{
+			name: "accepts certificate matching colon-separated uppercase pinned fingerprint",
+			givenOptions: TLSOptions{
+				CertSHA256: colonSeparated(strings.ToUpper(hex.EncodeToString(fingerprint[:]))),
+			},
+		},
+		{
+			name: "returns error if certificate does not match pinned fingerprint",
+			givenOptions: TLSOptions{
+				CertSHA256: strings.Repeat("00", sha256.Size),
+			},
+			wantErrorContains: "does not match pinned fingerprint",
+		},
+		{
+			name: "skips verification if insecure",
+			givenOptions: TLSOptions{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			server.TLS = &tls.Config{
+				Certificates: []tls.Certificate{certificate},
+			}
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			config, err := NewTLSConfig(tt.givenOptions)
+			require.NoError(t, err)
+			client := NewApiClient(server.URL, "some-token", 5, WithTLSConfig(config))
+
+			// WHEN
+			err = client.UpdateCustomConnections(context.Background(), "")
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenOptions      TLSOptions
+		givenCAData       []byte
+		wantErrorContains string
+	}{
+		{
+			name:         "accepts empty options",
+			givenOptions: TLSOptions{},
+		},
+		{
+			name: "returns error for invalid fingerprint",
+			givenOptions: TLSOptions{
+				CertSHA256: "not-a-fingerprint",
+			},
+			wantErrorContains: "invalid SHA-256 certificate fingerprint",
+		},
+		{
+			name: "returns error for fingerprint of wrong length",
+			givenOptions: TLSOptions{
+				CertSHA256: "abcdef",
+			},
+			wantErrorContains: "invalid SHA-256 certificate fingerprint",
+		},
+		{
+			name: "returns error for missing CA file",
+			givenOptions: TLSOptions{
+				CAFile: "does-not-exist.pem",
+			},
+			wantErrorContains: "failed to read CA file",
+		},
+		{
+			name:              "returns error for CA file without certificates",
+			givenCAData:       []byte("not a certificate"),
+			wantErrorContains: "no certificates found in CA file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			if tt.givenCAData != nil {
+				tt.givenOptions.CAFile = filepath.Join(t.TempDir(), "ca.pem")
+				require.NoError(t, os.WriteFile(tt.givenOptions.CAFile, tt.givenCAData, 0600))
+			}
+
+			// WHEN
+			_, err := NewTLSConfig(tt.givenOptions)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func colonSeparated(s string) string {
+	pairs := make([]string, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		pairs = append(pairs, s[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
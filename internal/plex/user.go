@@ -0,0 +1,62 @@
+package plex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	userEndpoint = "/user"
+)
+
+// UserDTO is the plex.tv account a token belongs to
+type UserDTO struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+	// Restricted is set for managed users of a Plex Home
+	Restricted bool `json:"restricted"`
+}
+
+// GetDisplayName returns the name of the user as shown by Plex (managed users do not have a username)
+func (u UserDTO) GetDisplayName() string {
+	if u.Title != "" {
+		return u.Title
+	}
+	return u.Username
+}
+
+// GetUser fetches the account the token belongs to. Requires the client to be created with AuthBaseURL and a
+// client identifier.
+func (c *ApiClient) GetUser(ctx context.Context) (UserDTO, error) {
+	if c.clientIdentifier == "" {
+		return UserDTO{}, fmt.Errorf("client identifier is required for fetching user")
+	}
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return UserDTO{}, err
+	}
+
+	u = u.JoinPath(userEndpoint)
+
+	req, err := c.createRequest(ctx, http.MethodGet, u.String())
+	if err != nil {
+		return UserDTO{}, err
+	}
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+
+	bytes, err := c.do(req)
+	if err != nil {
+		return UserDTO{}, err
+	}
+
+	var user UserDTO
+	if err := unmarshalJSON(bytes, &user); err != nil {
+		return UserDTO{}, err
+	}
+
+	return user, nil
+}
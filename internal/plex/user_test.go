@@ -0,0 +1,85 @@
+package plex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiClient_GetUser(t *testing.T) {
+	token := "some-token"
+	timeout := 5
+	clientIdentifier := "some-client-identifier"
+
+	tests := []struct {
+		name              string
+		givenStatusCode   int
+		givenData         []byte
+		wantUser          UserDTO
+		wantDisplayName   string
+		wantErrorContains string
+	}{
+		{
+			name:            "successfully fetches user",
+			givenStatusCode: 200,
+			givenData:       []byte(`{"id":12345,"uuid":"some-uuid","username":"some-user","title":"some-user","email":"user@example.com","restricted":false,"home":true,"homeAdmin":true,"authToken":"some-token"}`),
+			wantUser: UserDTO{
+				ID:       12345,
+				Username: "some-user",
+				Title:    "some-user",
+			},
+			wantDisplayName: "some-user",
+		},
+		{
+			name:            "successfully fetches managed user",
+			givenStatusCode: 200,
+			givenData:       []byte(`{"id":23456,"uuid":"other-uuid","username":"","title":"Kids","restricted":true,"home":true,"homeAdmin":false}`),
+			wantUser: UserDTO{
+				ID:         23456,
+				Title:      "Kids",
+				Restricted: true,
+			},
+			wantDisplayName: "Kids",
+		},
+		{
+			name:              "returns error for non-2xx response code",
+			givenStatusCode:   401,
+			wantErrorContains: "failed with status code 401 (401 Unauthorized)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, token, r.Header.Get(headerKeyToken))
+				assert.Equal(t, clientIdentifier, r.Header.Get(headerKeyClientIdentifier))
+				assert.Equal(t, contentTypeJSON, r.Header.Get(headerKeyAccept))
+				assert.Equal(t, userEndpoint, r.URL.Path)
+
+				w.WriteHeader(tt.givenStatusCode)
+				_, err := w.Write(tt.givenData)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			client := NewApiClient(server.URL, token, timeout, WithClientIdentifier(clientIdentifier))
+
+			// WHEN
+			user, err := client.GetUser(context.Background())
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantUser, user)
+				assert.Equal(t, tt.wantDisplayName, user.GetDisplayName())
+			}
+		})
+	}
+}
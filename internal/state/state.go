@@ -0,0 +1,123 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dirName  = "update-plex-ipv6-access-url"
+	fileName = "state.json"
+)
+
+// State is persisted between runs
+type State struct {
+	// Addresses published by the last successful update
+	PublishedAddrs []netip.Addr `json:"publishedAddrs,omitempty"`
+	// Custom access URLs written by the last successful update
+	ManagedURLs []string `json:"managedUrls,omitempty"`
+	// Previously published addresses which stay published until their grace period ends
+	RetiringAddrs []RetiringAddr `json:"retiringAddrs,omitempty"`
+	// Cached details of the server last updated
+	Server *ServerCache `json:"server,omitempty"`
+	// Identifier this tool uses towards plex.tv, generated on first use
+	ClientIdentifier string `json:"clientIdentifier,omitempty"`
+}
+
+type RetiringAddr struct {
+	Addr netip.Addr `json:"addr"`
+	// Time at which the address stops being published (end of grace period or valid lifetime)
+	Until time.Time `json:"until"`
+}
+
+type ServerCache struct {
+	// Address (or config file path in offline mode) of the server the details belong to
+	Server             string    `json:"server"`
+	MachineIdentifier  string    `json:"machineIdentifier,omitempty"`
+	PlexDirectHostname string    `json:"plexDirectHostname,omitempty"`
+	CachedAt           time.Time `json:"cachedAt"`
+	// Where the plex.direct hostname was determined from (e.g. certificate or plex.tv resources API)
+	HostnameSource string `json:"hostnameSource,omitempty"`
+}
+
+// IsFresh returns whether the details were cached less than ttl ago
+func (c *ServerCache) IsFresh(ttl time.Duration, now time.Time) bool {
+	return !c.CachedAt.IsZero() && now.Sub(c.CachedAt) < ttl
+}
+
+// DefaultPath returns the default state file path within the user's cache directory. Since the state determines
+// which URLs get published and removed, it is never stored in the temp directory (which may be shared), so the path
+// is empty if the user has no cache directory.
+func DefaultPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, dirName, fileName)
+}
+
+// ReadStateFile reads the state from the given path, returning an empty state if the file does not exist (yet)
+func ReadStateFile(path string) (State, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+
+	var s State
+	if err = json.Unmarshal(bytes, &s); err != nil {
+		return State{}, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	return s, nil
+}
+
+// WriteStateFile writes the state to the given path, creating any missing parent directories
+func WriteStateFile(path string, s State) error {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return writeFile(path, bytes)
+}
+
+// writeFile writes the data to the given path (only readable by the current user). The data is written to a temporary
+// file first, so an interrupted write cannot leave a corrupted file behind. The temporary file is created exclusively
+// with a random name, so it cannot be a planted file or symlink.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// No-op after a successful rename
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err = tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
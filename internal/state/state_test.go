@@ -0,0 +1,99 @@
+package state
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStateFile(t *testing.T) {
+	tests := []struct {
+		name              string
+		givenData         []byte
+		wantState         State
+		wantErrorContains string
+	}{
+		{
+			name:      "successfully reads state file",
+			givenData: []byte(`{"publishedAddrs":["2001:db8::1"]}`),
+			wantState: State{
+				PublishedAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			},
+		},
+		{
+			name:      "returns empty state if state file does not exist",
+			wantState: State{},
+		},
+		{
+			name:              "returns error for invalid state file",
+			givenData:         []byte(`{"publishedAddrs":`),
+			wantErrorContains: "failed to parse state file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.givenData != nil {
+				require.NoError(t, os.WriteFile(path, tt.givenData, 0600))
+			}
+
+			// WHEN
+			s, err := ReadStateFile(path)
+
+			// THEN
+			if tt.wantErrorContains != "" {
+				assert.ErrorContains(t, err, tt.wantErrorContains)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantState, s)
+			}
+		})
+	}
+}
+
+func TestWriteStateFile(t *testing.T) {
+	t.Run("writes state file which can be read again", func(t *testing.T) {
+		// GIVEN
+		path := filepath.Join(t.TempDir(), "some", "dir", "state.json")
+		s := State{
+			PublishedAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		}
+
+		// WHEN
+		err := WriteStateFile(path, s)
+
+		// THEN
+		require.NoError(t, err)
+		read, err := ReadStateFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, s, read)
+	})
+
+	t.Run("replaces existing state file without leaving temporary files behind", func(t *testing.T) {
+		// GIVEN
+		dir := t.TempDir()
+		path := filepath.Join(dir, "state.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"publishedAddrs":["2001:db8::1"]}`), 0600))
+		s := State{
+			PublishedAddrs: []netip.Addr{netip.MustParseAddr("2001:db8::2")},
+		}
+
+		// WHEN
+		err := WriteStateFile(path, s)
+
+		// THEN
+		require.NoError(t, err)
+		read, err := ReadStateFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, s, read)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	tokenFileName = "token"
+)
+
+// DefaultTokenStorePath returns the default path of the file storing the token obtained via login, within the user's
+// config directory. Unlike the state, the token is not stored in the cache directory (which may be wiped) and never in
+// the temp directory (which may be shared), so the path is empty if the user has no config directory.
+func DefaultTokenStorePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, dirName, tokenFileName)
+}
+
+// ReadTokenStore reads the token from the given path, returning an empty token if the file does not exist (yet)
+func ReadTokenStore(path string) (string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// WriteTokenStore writes the token to the given path (only readable by the current user), creating any missing
+// parent directories
+func WriteTokenStore(path string, token string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return writeFile(path, []byte(token+"\n"))
+}
@@ -0,0 +1,63 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTokenStore(t *testing.T) {
+	t.Run("returns empty token if token store does not exist", func(t *testing.T) {
+		// GIVEN
+		path := filepath.Join(t.TempDir(), "token")
+
+		// WHEN
+		token, err := ReadTokenStore(path)
+
+		// THEN
+		require.NoError(t, err)
+		assert.Empty(t, token)
+	})
+}
+
+func TestWriteTokenStore(t *testing.T) {
+	t.Run("writes token store which can be read again", func(t *testing.T) {
+		// GIVEN
+		path := filepath.Join(t.TempDir(), "some", "dir", "token")
+
+		// WHEN
+		err := WriteTokenStore(path, "some-token")
+
+		// THEN
+		require.NoError(t, err)
+		token, err := ReadTokenStore(path)
+		require.NoError(t, err)
+		assert.Equal(t, "some-token", token)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+	t.Run("does not follow leftover temporary file", func(t *testing.T) {
+		// GIVEN
+		dir := t.TempDir()
+		path := filepath.Join(dir, "token")
+		leftover := filepath.Join(dir, "token.tmp")
+		require.NoError(t, os.WriteFile(leftover, []byte("old-token\n"), 0644))
+		require.NoError(t, os.Chmod(leftover, 0644))
+
+		// WHEN
+		err := WriteTokenStore(path, "some-token")
+
+		// THEN
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		data, err := os.ReadFile(leftover)
+		require.NoError(t, err)
+		assert.Equal(t, "old-token\n", string(data))
+	})
+}
@@ -0,0 +1,180 @@
+//go:build linux
+
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Receive timeout used to periodically check whether the watcher has been closed
+	watcherReceiveTimeout = time.Second
+	watcherBufferSize     = 1 << 16
+
+	// Multicast group for IPv6 address events (not defined by syscall)
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// AddrWatcher notifies about IPv6 addresses being added to/removed from an interface,
+// based on rtnetlink RTM_NEWADDR/RTM_DELADDR events
+type AddrWatcher struct {
+	fd            int
+	interfaceName string
+	// Index of the interface when last seen, which changes whenever the interface is re-created
+	// (e.g. when a PPPoE link reconnects)
+	ifIndex int
+	// Looks up the name of the interface with the given index
+	lookupName func(index int) (string, error)
+	events     chan struct{}
+	done       chan struct{}
+	once       sync.Once
+	err        error
+}
+
+func NewAddrWatcher(interfaceName string) (*AddrWatcher, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	tv := syscall.NsecToTimeval(watcherReceiveTimeout.Nanoseconds())
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpIPv6IfAddr}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	w := &AddrWatcher{
+		fd:            fd,
+		interfaceName: interfaceName,
+		ifIndex:       iface.Index,
+		lookupName:    interfaceNameByIndex,
+		events:        make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+	go w.receive()
+
+	return w, nil
+}
+
+// Events returns a channel receiving a value whenever an address on the watched interface changed.
+// Events are coalesced, so a single value may represent multiple changes. The channel is closed
+// once the watcher stops, either because it was closed or because receiving events failed (see Err).
+func (w *AddrWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+// Err returns the error which caused the watcher to stop, if any
+func (w *AddrWatcher) Err() error {
+	return w.err
+}
+
+func (w *AddrWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *AddrWatcher) receive() {
+	defer func() {
+		_ = syscall.Close(w.fd)
+		close(w.events)
+	}()
+
+	buf := make([]byte, watcherBufferSize)
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(w.fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			// Socket receive buffer overflowed, meaning we missed events (treat as a change)
+			if errors.Is(err, syscall.ENOBUFS) {
+				w.notify()
+				continue
+			}
+			w.err = err
+			return
+		}
+
+		messages, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			w.err = err
+			return
+		}
+
+		for _, m := range messages {
+			if w.isRelevant(m) {
+				w.notify()
+			}
+		}
+	}
+}
+
+func (w *AddrWatcher) isRelevant(m syscall.NetlinkMessage) bool {
+	if m.Header.Type != syscall.RTM_NEWADDR && m.Header.Type != syscall.RTM_DELADDR {
+		return false
+	}
+
+	if len(m.Data) < syscall.SizeofIfAddrmsg {
+		return false
+	}
+
+	// struct ifaddrmsg: family (u8), prefixlen (u8), flags (u8), scope (u8), index (u32)
+	family := m.Data[0]
+	index := int(binary.NativeEndian.Uint32(m.Data[4:8]))
+	if family != syscall.AF_INET6 {
+		return false
+	}
+
+	if index == w.ifIndex {
+		return true
+	}
+
+	// The interface may have been re-created with a different index, so match by name
+	name, err := w.lookupName(index)
+	if err != nil || name != w.interfaceName {
+		return false
+	}
+
+	w.ifIndex = index
+	return true
+}
+
+func interfaceNameByIndex(index int) (string, error) {
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return "", err
+	}
+
+	return iface.Name, nil
+}
+
+func (w *AddrWatcher) notify() {
+	// Non-blocking send, a pending event already covers this change
+	select {
+	case w.events <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddrWatcher_isRelevant(t *testing.T) {
+	names := map[int]string{
+		2: "ppp0",
+		3: "eth0",
+		4: "ppp0",
+	}
+
+	tests := []struct {
+		name         string
+		givenType    uint16
+		givenFamily  uint8
+		givenIndex   uint32
+		wantRelevant bool
+		wantIfIndex  int
+	}{
+		{
+			name:         "new IPv6 address on watched interface is relevant",
+			givenType:    syscall.RTM_NEWADDR,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   2,
+			wantRelevant: true,
+			wantIfIndex:  2,
+		},
+		{
+			name:         "removed IPv6 address on watched interface is relevant",
+			givenType:    syscall.RTM_DELADDR,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   2,
+			wantRelevant: true,
+			wantIfIndex:  2,
+		},
+		{
+			name:         "IPv6 address on re-created interface with new index is relevant",
+			givenType:    syscall.RTM_NEWADDR,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   4,
+			wantRelevant: true,
+			wantIfIndex:  4,
+		},
+		{
+			name:         "IPv6 address on other interface is not relevant",
+			givenType:    syscall.RTM_NEWADDR,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   3,
+			wantRelevant: false,
+			wantIfIndex:  2,
+		},
+		{
+			name:         "IPv6 address on unknown interface is not relevant",
+			givenType:    syscall.RTM_NEWADDR,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   5,
+			wantRelevant: false,
+			wantIfIndex:  2,
+		},
+		{
+			name:         "IPv4 address on watched interface is not relevant",
+			givenType:    syscall.RTM_NEWADDR,
+			givenFamily:  syscall.AF_INET,
+			givenIndex:   2,
+			wantRelevant: false,
+			wantIfIndex:  2,
+		},
+		{
+			name:         "link event is not relevant",
+			givenType:    syscall.RTM_NEWLINK,
+			givenFamily:  syscall.AF_INET6,
+			givenIndex:   2,
+			wantRelevant: false,
+			wantIfIndex:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			w := &AddrWatcher{
+				interfaceName: "ppp0",
+				ifIndex:       2,
+				lookupName: func(index int) (string, error) {
+					name, ok := names[index]
+					if !ok {
+						return "", errors.New("no such network interface")
+					}
+					return name, nil
+				},
+			}
+			data := make([]byte, syscall.SizeofIfAddrmsg)
+			data[0] = tt.givenFamily
+			binary.NativeEndian.PutUint32(data[4:8], tt.givenIndex)
+			m := syscall.NetlinkMessage{
+				Header: syscall.NlMsghdr{Type: tt.givenType},
+				Data:   data,
+			}
+
+			// WHEN
+			relevant := w.isRelevant(m)
+
+			// THEN
+			assert.Equal(t, tt.wantRelevant, relevant)
+			assert.Equal(t, tt.wantIfIndex, w.ifIndex)
+		})
+	}
+}
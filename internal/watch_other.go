@@ -0,0 +1,28 @@
+//go:build !linux
+
+package internal
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// AddrWatcher notifies about IPv6 addresses being added to/removed from an interface.
+// Watching is only supported on Linux.
+type AddrWatcher struct{}
+
+func NewAddrWatcher(_ string) (*AddrWatcher, error) {
+	return nil, fmt.Errorf("watching for address changes is not supported on %s", runtime.GOOS)
+}
+
+func (w *AddrWatcher) Events() <-chan struct{} {
+	return nil
+}
+
+func (w *AddrWatcher) Err() error {
+	return nil
+}
+
+func (w *AddrWatcher) Close() error {
+	return nil
+}